@@ -0,0 +1,148 @@
+package airing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// JST is the time zone MAL broadcast times are given in.
+var JST = time.FixedZone("JST", 9*60*60)
+
+const week = 7 * 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Show is the broadcast schedule of one anime. Episodes are assumed to air
+// weekly from the first broadcast without breaks, which holds for most
+// seasonal shows but drifts for those with recap weeks.
+type Show struct {
+	Anime      api.Anime            `json:"anime"`
+	ListStatus *api.AnimeListStatus `json:"list_status,omitempty"`
+	// FirstEpisode is the first broadcast on or after start_date.
+	FirstEpisode time.Time `json:"first_episode"`
+	// Aired is how many episodes have been broadcast by now.
+	Aired int `json:"aired"`
+	// NextEpisode is zero once the last episode has aired.
+	NextEpisode   time.Time `json:"next_episode"`
+	NextNumber    int       `json:"next_number,omitempty"`
+	Watched       int       `json:"watched"`
+	Backlog       int       `json:"backlog"`
+	LatestEpisode time.Time `json:"latest_episode"`
+}
+
+// NewShow computes the schedule of a at now. It fails when a has no usable
+// broadcast time or start date.
+func NewShow(a api.Anime, status *api.AnimeListStatus, now time.Time) (*Show, error) {
+	first, err := FirstEpisode(a)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Show{Anime: a, ListStatus: status, FirstEpisode: first}
+	if !now.Before(first) {
+		s.Aired = int(now.Sub(first)/week) + 1
+	}
+	if a.NumEpisodes > 0 && s.Aired > a.NumEpisodes {
+		s.Aired = a.NumEpisodes
+	}
+	if s.Aired > 0 {
+		s.LatestEpisode = s.Episode(s.Aired)
+	}
+	if a.NumEpisodes == 0 || s.Aired < a.NumEpisodes {
+		s.NextNumber = s.Aired + 1
+		s.NextEpisode = s.Episode(s.NextNumber)
+	}
+
+	if status != nil {
+		s.Watched = status.NumEpisodesWatched
+	}
+	if s.Backlog = s.Aired - s.Watched; s.Backlog < 0 {
+		s.Backlog = 0
+	}
+	return s, nil
+}
+
+// Episode is the broadcast time of episode n, counting from 1.
+func (s *Show) Episode(n int) time.Time {
+	return s.FirstEpisode.Add(time.Duration(n-1) * week)
+}
+
+// EpisodesBetween lists the episodes broadcast in [from, to).
+func (s *Show) EpisodesBetween(from, to time.Time) []int {
+	var episodes []int
+	n := 1
+	if from.After(s.FirstEpisode) {
+		n = int(from.Sub(s.FirstEpisode)/week) + 1
+	}
+	for ; s.Anime.NumEpisodes == 0 || n <= s.Anime.NumEpisodes; n++ {
+		t := s.Episode(n)
+		if !t.Before(to) {
+			break
+		}
+		if !t.Before(from) {
+			episodes = append(episodes, n)
+		}
+	}
+	return episodes
+}
+
+// FirstEpisode is the first broadcast slot on or after the start date of a,
+// in JST.
+func FirstEpisode(a api.Anime) (time.Time, error) {
+	if a.Broadcast == nil || a.Broadcast.DayOfTheWeek == "" || a.Broadcast.StartTime == "" {
+		return time.Time{}, fmt.Errorf("%s has no broadcast time", a.Title)
+	}
+	weekday, ok := weekdays[strings.ToLower(a.Broadcast.DayOfTheWeek)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s has an irregular broadcast day %q", a.Title, a.Broadcast.DayOfTheWeek)
+	}
+	var hour, minute int
+	if _, err := fmt.Sscanf(a.Broadcast.StartTime, "%d:%d", &hour, &minute); err != nil {
+		return time.Time{}, fmt.Errorf("%s has an invalid broadcast time %q", a.Title, a.Broadcast.StartTime)
+	}
+	start, err := time.ParseInLocation("2006-01-02", a.StartDate, JST)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s has no exact start date", a.Title)
+	}
+
+	days := (int(weekday) - int(start.Weekday()) + 7) % 7
+	return start.AddDate(0, 0, days).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute), nil
+}
+
+// Schedule builds the shows of the airing entries of list, sorted by next
+// episode. Entries without a usable broadcast time are returned as skipped.
+func Schedule(list []api.AnimeListEntry, now time.Time) (shows []*Show, skipped []string) {
+	for _, entry := range list {
+		if entry.Node.Status != "currently_airing" && entry.Node.Status != "not_yet_aired" {
+			continue
+		}
+		status := entry.ListStatus
+		s, err := NewShow(entry.Node, &status, now)
+		if err != nil {
+			skipped = append(skipped, entry.Node.Title)
+			continue
+		}
+		shows = append(shows, s)
+	}
+
+	sort.SliceStable(shows, func(i, j int) bool {
+		a, b := shows[i].NextEpisode, shows[j].NextEpisode
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return shows, skipped
+}
@@ -0,0 +1,102 @@
+package airing
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	icsTimeFormat = "20060102T150405Z"
+	// icsLineLimit is the maximum line length in octets before folding,
+	// RFC 5545 section 3.1.
+	icsLineLimit = 75
+	// defaultEpisodeLength is used when MAL has no average duration.
+	defaultEpisodeLength = 24 * time.Minute
+)
+
+// WriteICS writes shows as an RFC 5545 calendar with one weekly recurring
+// event per show. Times are written in UTC, JST has no daylight saving time
+// so the weekly rule stays exact.
+func WriteICS(w io.Writer, shows []*Show, now time.Time) error {
+	ics := &icsWriter{}
+	ics.line("BEGIN:VCALENDAR")
+	ics.line("VERSION:2.0")
+	ics.line("PRODID:-//ani-track//airing schedule//EN")
+	ics.line("CALSCALE:GREGORIAN")
+	ics.line("METHOD:PUBLISH")
+	ics.line("X-WR-CALNAME:Airing anime")
+	ics.line("REFRESH-INTERVAL;VALUE=DURATION:PT6H")
+
+	for _, s := range shows {
+		length := time.Duration(s.Anime.AverageEpisodeDuration) * time.Second
+		if length <= 0 {
+			length = defaultEpisodeLength
+		}
+		url := fmt.Sprintf("https://myanimelist.net/anime/%d", s.Anime.ID)
+
+		description := fmt.Sprintf("%d episodes aired", s.Aired)
+		if s.Anime.NumEpisodes > 0 {
+			description = fmt.Sprintf("%d of %d episodes aired", s.Aired, s.Anime.NumEpisodes)
+		}
+		if s.ListStatus != nil {
+			description += fmt.Sprintf(", %d watched", s.Watched)
+		}
+		description += "\n" + url
+
+		ics.line("BEGIN:VEVENT")
+		ics.line(fmt.Sprintf("UID:anime-%d@ani-track", s.Anime.ID))
+		ics.line("DTSTAMP:" + now.UTC().Format(icsTimeFormat))
+		ics.line("DTSTART:" + s.FirstEpisode.UTC().Format(icsTimeFormat))
+		ics.line("DTEND:" + s.FirstEpisode.Add(length).UTC().Format(icsTimeFormat))
+		if s.Anime.NumEpisodes > 0 {
+			ics.line(fmt.Sprintf("RRULE:FREQ=WEEKLY;COUNT=%d", s.Anime.NumEpisodes))
+		} else {
+			ics.line("RRULE:FREQ=WEEKLY")
+		}
+		ics.line("SUMMARY:" + icsEscape(s.Anime.Title))
+		ics.line("DESCRIPTION:" + icsEscape(description))
+		ics.line("URL:" + url)
+		ics.line("TRANSP:TRANSPARENT")
+		ics.line("END:VEVENT")
+	}
+
+	ics.line("END:VCALENDAR")
+	_, err := io.WriteString(w, ics.String())
+	return err
+}
+
+type icsWriter struct {
+	strings.Builder
+}
+
+// line writes a content line terminated by CRLF, folding it into
+// continuation lines starting with a space so none exceeds 75 octets.
+// Folds never split a UTF-8 sequence.
+func (w *icsWriter) line(s string) {
+	limit := icsLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = icsLineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
@@ -3,71 +3,321 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	apiBaseURL = "https://api.myanimelist.net/v2/"
+
+	maxListPageSize = 1000
+
+	// AnimeDetailFields are the anime fields used across the commands that
+	// need more than a title.
+	AnimeDetailFields = "id,title,main_picture,alternative_titles,start_date,end_date,synopsis,mean,rank," +
+		"popularity,num_list_users,nsfw,genres,media_type,status,num_episodes,start_season,broadcast,source," +
+		"average_episode_duration,rating,studios,my_list_status"
+	// AnimeListFields are the node fields requested alongside list_status
+	// when the list itself should carry details.
+	AnimeListFields = "id,title,alternative_titles,start_date,end_date,mean,rank,popularity,num_list_users," +
+		"genres,media_type,status,num_episodes,start_season,broadcast,source,average_episode_duration,rating,studios"
+	MangaDetailFields = "id,title,main_picture,alternative_titles,start_date,end_date,synopsis,mean,rank," +
+		"popularity,nsfw,genres,media_type,status,num_volumes,num_chapters,authors{first_name,last_name},my_list_status"
 )
 
-type AnimeSearchResult struct {
-	Data []struct {
-		Node struct {
-			Title string `json:"title"`
-		} `json:"node"`
-	} `json:"data"`
+// SearchAnime searches anime by title. fields selects the anime fields to
+// include, MAL returns only id, title and main_picture by default. Adult
+// titles are left out unless nsfw is set.
+func SearchAnime(query string, limit, offset int, fields string, nsfw bool, accessToken string) ([]Anime, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("offset", strconv.Itoa(offset))
+	if fields != "" {
+		params.Set("fields", fields)
+	}
+	if nsfw {
+		params.Set("nsfw", "true")
+	}
+	searchURL := fmt.Sprintf("%sanime?%s", apiBaseURL, params.Encode())
+
+	var result animeNodesResult
+	if err := getJSON(searchURL, accessToken, &result); err != nil {
+		return nil, err
+	}
+
+	return result.nodes(), nil
 }
 
-func SearchAnime(query, limit, accessToken string) (*AnimeSearchResult, error) {
-	searchURL := fmt.Sprintf("%sanime?q=%s&limit=%s", apiBaseURL, query, limit)
-	req, err := http.NewRequest("GET", searchURL, nil)
-	if err != nil {
+type UserAnimeListResult struct {
+	Data   []AnimeListEntry `json:"data"`
+	Paging Paging           `json:"paging"`
+}
+
+func GetUserAnimeList(username, limit, accessToken string) (*UserAnimeListResult, error) {
+	userListURL := fmt.Sprintf("%susers/%s/animelist?fields=list_status&limit=%s", apiBaseURL, username, limit)
+
+	var result UserAnimeListResult
+	if err := getJSON(userListURL, accessToken, &result); err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return &result, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+// GetFullUserAnimeList follows the paging links and returns the whole list.
+// fields selects the anime fields to include next to list_status.
+func GetFullUserAnimeList(username, fields, accessToken string) ([]AnimeListEntry, error) {
+	query := url.Values{}
+	query.Set("fields", joinFields("list_status", fields))
+	query.Set("limit", strconv.Itoa(maxListPageSize))
+	query.Set("nsfw", "true")
+	nextURL := fmt.Sprintf("%susers/%s/animelist?%s", apiBaseURL, url.PathEscape(username), query.Encode())
+
+	var entries []AnimeListEntry
+	for nextURL != "" {
+		var page UserAnimeListResult
+		if err := getJSON(nextURL, accessToken, &page); err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Data...)
+		nextURL = page.Paging.Next
+	}
+
+	return entries, nil
+}
+
+type UserMangaListResult struct {
+	Data   []MangaListEntry `json:"data"`
+	Paging Paging           `json:"paging"`
+}
+
+func GetFullUserMangaList(username, fields, accessToken string) ([]MangaListEntry, error) {
+	query := url.Values{}
+	query.Set("fields", joinFields("list_status", fields))
+	query.Set("limit", strconv.Itoa(maxListPageSize))
+	query.Set("nsfw", "true")
+	nextURL := fmt.Sprintf("%susers/%s/mangalist?%s", apiBaseURL, url.PathEscape(username), query.Encode())
+
+	var entries []MangaListEntry
+	for nextURL != "" {
+		var page UserMangaListResult
+		if err := getJSON(nextURL, accessToken, &page); err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Data...)
+		nextURL = page.Paging.Next
+	}
+
+	return entries, nil
+}
+
+func GetAnimeDetails(id int, fields, accessToken string) (*Anime, error) {
+	detailsURL := fmt.Sprintf("%sanime/%d?fields=%s", apiBaseURL, id, url.QueryEscape(fields))
+
+	var result Anime
+	if err := getJSON(detailsURL, accessToken, &result); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var result AnimeSearchResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	return &result, nil
+}
+
+func GetMangaDetails(id int, fields, accessToken string) (*Manga, error) {
+	detailsURL := fmt.Sprintf("%smanga/%d?fields=%s", apiBaseURL, id, url.QueryEscape(fields))
+
+	var result Manga
+	if err := getJSON(detailsURL, accessToken, &result); err != nil {
 		return nil, err
 	}
 
 	return &result, nil
 }
 
-type UserAnimeListResult struct {
+// RankingTypes are the values accepted by GetAnimeRanking.
+var RankingTypes = []string{"all", "airing", "upcoming", "tv", "ova", "movie", "special", "bypopularity", "favorite"}
+
+type animeNodesResult struct {
 	Data []struct {
-		Node struct {
-			Title string `json:"title"`
-		} `json:"node"`
+		Node Anime `json:"node"`
 	} `json:"data"`
+	Paging Paging `json:"paging"`
 }
 
-func GetUserAnimeList(username, limit, accessToken string) (*UserAnimeListResult, error) {
-	userListURL := fmt.Sprintf("%susers/%s/animelist?fields=list_status&limit=%s", apiBaseURL, username, limit)
-	req, err := http.NewRequest("GET", userListURL, nil)
-	if err != nil {
+func (r animeNodesResult) nodes() []Anime {
+	nodes := make([]Anime, len(r.Data))
+	for i, d := range r.Data {
+		nodes[i] = d.Node
+	}
+	return nodes
+}
+
+func GetAnimeRanking(rankingType string, limit, offset int, fields, accessToken string) ([]Anime, error) {
+	query := url.Values{}
+	query.Set("ranking_type", rankingType)
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("fields", fields)
+	rankingURL := fmt.Sprintf("%sanime/ranking?%s", apiBaseURL, query.Encode())
+
+	var result animeNodesResult
+	if err := getJSON(rankingURL, accessToken, &result); err != nil {
 		return nil, err
 	}
 
+	return result.nodes(), nil
+}
+
+// GetSeasonalAnime lists anime of a season ("winter", "spring", "summer"
+// or "fall"), most popular first.
+func GetSeasonalAnime(year int, season string, limit int, fields, accessToken string) ([]Anime, error) {
+	query := url.Values{}
+	query.Set("sort", "anime_num_list_users")
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("fields", fields)
+	seasonURL := fmt.Sprintf("%sanime/season/%d/%s?%s", apiBaseURL, year, url.PathEscape(season), query.Encode())
+
+	var result animeNodesResult
+	if err := getJSON(seasonURL, accessToken, &result); err != nil {
+		return nil, err
+	}
+
+	return result.nodes(), nil
+}
+
+// CurrentSeason returns the anime season t falls in.
+func CurrentSeason(t time.Time) (int, string) {
+	seasons := []string{"winter", "spring", "summer", "fall"}
+	return t.Year(), seasons[(int(t.Month())-1)/3]
+}
+
+func joinFields(fields ...string) string {
+	var nonEmpty []string
+	for _, f := range fields {
+		if f != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	return strings.Join(nonEmpty, ",")
+}
+
+type UserInfo struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	JoinedAt string `json:"joined_at"`
+}
+
+func GetMyUserInfo(accessToken string) (*UserInfo, error) {
+	userURL := fmt.Sprintf("%susers/@me", apiBaseURL)
+
+	var result UserInfo
+	if err := getJSON(userURL, accessToken, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// APIError is returned for any non-2xx response.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("unexpected status: %s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+func newAPIError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 4096)); err == nil && json.Unmarshal(data, &body) == nil {
+		apiErr.Message = body.Message
+		if apiErr.Message == "" {
+			apiErr.Message = body.Error
+		}
+	}
+
+	return apiErr
+}
+
+// getJSON fetches requestURL and decodes the JSON body into v, going through
+// the response cache for endpoints that have a TTL.
+func getJSON(requestURL, accessToken string, v interface{}) error {
+	ttl := cacheTTL(requestURL)
+
+	var entry *cacheEntry
+	if cacheEnabled(ttl) {
+		entry = loadCacheEntry(requestURL)
+		if entry != nil && (cacheOptions.Offline || (!cacheOptions.Refresh && time.Since(entry.StoredAt) < ttl)) {
+			return json.Unmarshal(entry.Body, v)
+		}
+	}
+	if cacheOptions.Offline {
+		return fmt.Errorf("%w: %s", ErrNotCached, requestURL)
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return err
+	}
+
 	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	var result UserAnimeListResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.StoredAt = time.Now()
+		saveCacheEntry(entry)
+		return json.Unmarshal(entry.Body, v)
 	}
 
-	return &result, nil
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+
+	if cacheEnabled(ttl) {
+		saveCacheEntry(&cacheEntry{
+			URL:          requestURL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			StoredAt:     time.Now(),
+			Body:         body,
+		})
+	}
+
+	return nil
 }
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrNotCached = errors.New("offline and no cached response")
+
+// CacheOptions controls the on-disk response cache.
+type CacheOptions struct {
+	// Dir holds the cached responses, caching is off when empty.
+	Dir string
+	// Disabled neither reads nor writes the cache.
+	Disabled bool
+	// Refresh ignores fresh entries and revalidates them with the server.
+	Refresh bool
+	// Offline serves every request from the cache regardless of age and
+	// fails instead of touching the network.
+	Offline bool
+}
+
+var cacheOptions CacheOptions
+
+func SetCacheOptions(opts CacheOptions) {
+	cacheOptions = opts
+}
+
+type cacheRule struct {
+	pattern *regexp.Regexp
+	ttl     time.Duration
+}
+
+// cacheRules map API paths (relative to apiBaseURL) to how long a response
+// is served without asking the server again. Endpoints not listed here are
+// never cached.
+var cacheRules = []cacheRule{
+	{regexp.MustCompile(`^(anime|manga)/\d+$`), 72 * time.Hour},
+	{regexp.MustCompile(`^(anime|manga)/ranking$`), 24 * time.Hour},
+	{regexp.MustCompile(`^anime/season/`), 6 * time.Hour},
+	{regexp.MustCompile(`^(anime|manga)$`), time.Hour},
+	{regexp.MustCompile(`^users/[^/]+/(anime|manga)list$`), listStatusTTL},
+	{regexp.MustCompile(`^users/@me$`), 10 * time.Minute},
+}
+
+// listStatusTTL caps the TTL of responses that embed the user's list
+// status, which changes with the list rather than with the anime.
+const listStatusTTL = 5 * time.Minute
+
+func cacheTTL(requestURL string) time.Duration {
+	path := apiPath(requestURL)
+	for _, rule := range cacheRules {
+		if rule.pattern.MatchString(path) {
+			if hasListStatus(requestURL) {
+				return min(rule.ttl, listStatusTTL)
+			}
+			return rule.ttl
+		}
+	}
+	return 0
+}
+
+// hasListStatus reports whether the request asks for my_list_status.
+func hasListStatus(requestURL string) bool {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return false
+	}
+	for _, field := range strings.Split(u.Query().Get("fields"), ",") {
+		if strings.TrimSpace(field) == "my_list_status" {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheEnabled(ttl time.Duration) bool {
+	return ttl > 0 && cacheOptions.Dir != "" && !cacheOptions.Disabled
+}
+
+func apiPath(requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return ""
+	}
+	base, _ := url.Parse(apiBaseURL)
+	return strings.TrimPrefix(u.Path, base.Path)
+}
+
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	StoredAt     time.Time `json:"stored_at"`
+	Body         []byte    `json:"body"`
+}
+
+// cacheFile groups entries by endpoint path so all cached pages of one
+// endpoint can be dropped together.
+func cacheFile(requestURL string) string {
+	sum := sha256.Sum256([]byte(requestURL))
+	dir := strings.NewReplacer("/", "_", "@", "_").Replace(apiPath(requestURL))
+	return filepath.Join(cacheOptions.Dir, dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func loadCacheEntry(requestURL string) *cacheEntry {
+	data, err := os.ReadFile(cacheFile(requestURL))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != requestURL {
+		return nil
+	}
+	return &entry
+}
+
+// saveCacheEntry is best effort, a failing cache must never fail a request.
+func saveCacheEntry(entry *cacheEntry) {
+	path := cacheFile(entry.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// InvalidateCache drops every cached response of the endpoint at path, e.g.
+// "users/@me/animelist" after the list was changed.
+func InvalidateCache(path string) {
+	if cacheOptions.Dir == "" {
+		return
+	}
+	dir := strings.NewReplacer("/", "_", "@", "_").Replace(path)
+	os.RemoveAll(filepath.Join(cacheOptions.Dir, dir))
+}
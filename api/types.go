@@ -0,0 +1,136 @@
+package api
+
+import "time"
+
+type Picture struct {
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+type AlternativeTitles struct {
+	Synonyms []string `json:"synonyms"`
+	En       string   `json:"en"`
+	Ja       string   `json:"ja"`
+}
+
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Studio struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Season struct {
+	Year   int    `json:"year"`
+	Season string `json:"season"`
+}
+
+// Broadcast times are in JST.
+type Broadcast struct {
+	DayOfTheWeek string `json:"day_of_the_week"`
+	StartTime    string `json:"start_time"`
+}
+
+type AnimeListStatus struct {
+	Status             string    `json:"status"`
+	Score              int       `json:"score"`
+	NumEpisodesWatched int       `json:"num_episodes_watched"`
+	IsRewatching       bool      `json:"is_rewatching"`
+	StartDate          string    `json:"start_date,omitempty"`
+	FinishDate         string    `json:"finish_date,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+type RelatedAnime struct {
+	Node                  Anime  `json:"node"`
+	RelationType          string `json:"relation_type"`
+	RelationTypeFormatted string `json:"relation_type_formatted"`
+}
+
+// Anime holds the fields of the anime endpoints, which ones are filled in
+// depends on the fields requested.
+type Anime struct {
+	ID                     int               `json:"id"`
+	Title                  string            `json:"title"`
+	MainPicture            Picture           `json:"main_picture"`
+	AlternativeTitles      AlternativeTitles `json:"alternative_titles"`
+	StartDate              string            `json:"start_date,omitempty"`
+	EndDate                string            `json:"end_date,omitempty"`
+	Synopsis               string            `json:"synopsis,omitempty"`
+	Mean                   float64           `json:"mean,omitempty"`
+	Rank                   int               `json:"rank,omitempty"`
+	Popularity             int               `json:"popularity,omitempty"`
+	NumListUsers           int               `json:"num_list_users,omitempty"`
+	NSFW                   string            `json:"nsfw,omitempty"`
+	Genres                 []Genre           `json:"genres,omitempty"`
+	MediaType              string            `json:"media_type,omitempty"`
+	Status                 string            `json:"status,omitempty"`
+	NumEpisodes            int               `json:"num_episodes,omitempty"`
+	StartSeason            *Season           `json:"start_season,omitempty"`
+	Broadcast              *Broadcast        `json:"broadcast,omitempty"`
+	Source                 string            `json:"source,omitempty"`
+	AverageEpisodeDuration int               `json:"average_episode_duration,omitempty"`
+	Rating                 string            `json:"rating,omitempty"`
+	Studios                []Studio          `json:"studios,omitempty"`
+	MyListStatus           *AnimeListStatus  `json:"my_list_status,omitempty"`
+	RelatedAnime           []RelatedAnime    `json:"related_anime,omitempty"`
+}
+
+type AnimeListEntry struct {
+	Node       Anime           `json:"node"`
+	ListStatus AnimeListStatus `json:"list_status"`
+}
+
+type MangaListStatus struct {
+	Status          string    `json:"status"`
+	Score           int       `json:"score"`
+	NumVolumesRead  int       `json:"num_volumes_read"`
+	NumChaptersRead int       `json:"num_chapters_read"`
+	IsRereading     bool      `json:"is_rereading"`
+	StartDate       string    `json:"start_date,omitempty"`
+	FinishDate      string    `json:"finish_date,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type Author struct {
+	Node struct {
+		ID        int    `json:"id"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	} `json:"node"`
+	Role string `json:"role"`
+}
+
+type Manga struct {
+	ID                int               `json:"id"`
+	Title             string            `json:"title"`
+	MainPicture       Picture           `json:"main_picture"`
+	AlternativeTitles AlternativeTitles `json:"alternative_titles"`
+	StartDate         string            `json:"start_date,omitempty"`
+	EndDate           string            `json:"end_date,omitempty"`
+	Synopsis          string            `json:"synopsis,omitempty"`
+	Mean              float64           `json:"mean,omitempty"`
+	Rank              int               `json:"rank,omitempty"`
+	Popularity        int               `json:"popularity,omitempty"`
+	NSFW              string            `json:"nsfw,omitempty"`
+	Genres            []Genre           `json:"genres,omitempty"`
+	MediaType         string            `json:"media_type,omitempty"`
+	Status            string            `json:"status,omitempty"`
+	NumVolumes        int               `json:"num_volumes,omitempty"`
+	NumChapters       int               `json:"num_chapters,omitempty"`
+	Authors           []Author          `json:"authors,omitempty"`
+	MyListStatus      *MangaListStatus  `json:"my_list_status,omitempty"`
+}
+
+type MangaListEntry struct {
+	Node       Manga           `json:"node"`
+	ListStatus MangaListStatus `json:"list_status"`
+}
+
+type Paging struct {
+	Previous string `json:"previous,omitempty"`
+	Next     string `json:"next,omitempty"`
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrOffline = errors.New("offline mode, request not sent")
+
+// AnimeListUpdate holds the my_list_status fields to change, nil fields are
+// left as they are.
+type AnimeListUpdate struct {
+	Status             *string `json:"status,omitempty"`
+	Score              *int    `json:"score,omitempty"`
+	NumWatchedEpisodes *int    `json:"num_watched_episodes,omitempty"`
+	IsRewatching       *bool   `json:"is_rewatching,omitempty"`
+	StartDate          *string `json:"start_date,omitempty"`
+	FinishDate         *string `json:"finish_date,omitempty"`
+}
+
+func (u AnimeListUpdate) values() url.Values {
+	values := url.Values{}
+	if u.Status != nil {
+		values.Set("status", *u.Status)
+	}
+	if u.Score != nil {
+		values.Set("score", strconv.Itoa(*u.Score))
+	}
+	if u.NumWatchedEpisodes != nil {
+		values.Set("num_watched_episodes", strconv.Itoa(*u.NumWatchedEpisodes))
+	}
+	if u.IsRewatching != nil {
+		values.Set("is_rewatching", strconv.FormatBool(*u.IsRewatching))
+	}
+	if u.StartDate != nil {
+		values.Set("start_date", *u.StartDate)
+	}
+	if u.FinishDate != nil {
+		values.Set("finish_date", *u.FinishDate)
+	}
+	return values
+}
+
+// String describes the update for humans, e.g. "status=watching episodes=5".
+func (u AnimeListUpdate) String() string {
+	var parts []string
+	if u.Status != nil {
+		parts = append(parts, "status="+*u.Status)
+	}
+	if u.Score != nil {
+		parts = append(parts, "score="+strconv.Itoa(*u.Score))
+	}
+	if u.NumWatchedEpisodes != nil {
+		parts = append(parts, "episodes="+strconv.Itoa(*u.NumWatchedEpisodes))
+	}
+	if u.IsRewatching != nil {
+		parts = append(parts, "rewatching="+strconv.FormatBool(*u.IsRewatching))
+	}
+	if u.StartDate != nil {
+		parts = append(parts, "start="+*u.StartDate)
+	}
+	if u.FinishDate != nil {
+		parts = append(parts, "finish="+*u.FinishDate)
+	}
+	return strings.Join(parts, " ")
+}
+
+func UpdateAnimeListStatus(id int, update AnimeListUpdate, accessToken string) (*AnimeListStatus, error) {
+	statusURL := fmt.Sprintf("%sanime/%d/my_list_status", apiBaseURL, id)
+
+	var result AnimeListStatus
+	if err := sendForm("PATCH", statusURL, accessToken, update.values(), &result); err != nil {
+		return nil, err
+	}
+
+	invalidateListCaches(id)
+	return &result, nil
+}
+
+func DeleteAnimeListItem(id int, accessToken string) error {
+	statusURL := fmt.Sprintf("%sanime/%d/my_list_status", apiBaseURL, id)
+
+	if err := sendForm("DELETE", statusURL, accessToken, nil, nil); err != nil {
+		return err
+	}
+
+	invalidateListCaches(id)
+	return nil
+}
+
+// invalidateListCaches drops cached responses that embed the list status of
+// the anime with the given id.
+func invalidateListCaches(id int) {
+	InvalidateCache("users/@me/animelist")
+	InvalidateCache(fmt.Sprintf("anime/%d", id))
+}
+
+// IsUnavailable reports whether err means MAL could not be reached or is
+// temporarily failing, as opposed to rejecting the request.
+func IsUnavailable(err error) bool {
+	if errors.Is(err, ErrOffline) || errors.Is(err, ErrNotCached) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sendForm(method, requestURL, accessToken string, form url.Values, v interface{}) error {
+	if cacheOptions.Offline {
+		return ErrOffline
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -1,17 +1,24 @@
 package auth
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/browser"
 	"golang.org/x/oauth2"
@@ -21,39 +28,124 @@ const (
 	AnitrackTokenFileName = ".anitrack.conf"
 )
 
+const (
+	PKCEMethodPlain = "plain"
+	PKCEMethodS256  = "S256"
+
+	DefaultLoginTimeout = 5 * time.Minute
+	DefaultCallbackPort = 9999
+	callbackPath        = "/oauth/callback"
+)
+
+// Provider describes an OAuth authorization server and the PKCE method it
+// accepts.
+type Provider struct {
+	Name       string
+	AuthURL    string
+	TokenURL   string
+	PKCEMethod string
+	// RevokeURL is empty when the provider has no revocation endpoint.
+	RevokeURL string
+}
+
+// MyAnimeList only supports the plain PKCE method and has no revocation
+// endpoint, tokens stay valid until they expire or the app is removed.
+var MyAnimeList = Provider{
+	Name:       "myanimelist",
+	AuthURL:    "https://myanimelist.net/v1/oauth2/authorize",
+	TokenURL:   "https://myanimelist.net/v1/oauth2/token",
+	PKCEMethod: PKCEMethodPlain,
+}
+
+var ErrRevocationUnsupported = errors.New("provider does not support token revocation")
+
 var (
-	config *oauth2.Config
-	token  *oauth2.Token
-	server *http.Server
+	config   *oauth2.Config
+	provider = MyAnimeList
+	server   *http.Server
 )
 
 func InitializeOAuthConfig() *oauth2.Config {
 	config = &oauth2.Config{
 		Scopes: []string{"read"},
 		Endpoint: oauth2.Endpoint{
-			AuthURL:   "https://myanimelist.net/v1/oauth2/authorize",
-			TokenURL:  "https://myanimelist.net/v1/oauth2/token",
+			AuthURL:   provider.AuthURL,
+			TokenURL:  provider.TokenURL,
 			AuthStyle: oauth2.AuthStyleInParams,
 		},
-		RedirectURL: "http://localhost:9999/oauth/callback",
+		RedirectURL: DefaultRedirectURL(DefaultCallbackPort),
 	}
 	return config
 }
 
+func DefaultRedirectURL(port int) string {
+	return fmt.Sprintf("http://localhost:%d%s", port, callbackPath)
+}
+
 func GetTokenFilePath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(homeDir, AnitrackTokenFileName), nil
+	return filepath.Join(homeDir, profileFileName(AnitrackTokenFileName)), nil
 }
 
-func GetToken() (*oauth2.Token, error) {
-	codeChan := make(chan string)
-	defer close(codeChan)
+type LoginOptions struct {
+	// NoBrowser skips opening a browser and reads a pasted redirect URL or
+	// code from stdin. The callback server still runs.
+	NoBrowser bool
+	// Timeout bounds how long to wait for the authorization code.
+	Timeout time.Duration
+	// Port of the loopback callback server, used when RedirectURL is empty.
+	Port int
+	// RedirectURL overrides the redirect URI registered with the MAL app.
+	// It must point at a loopback host.
+	RedirectURL string
+}
 
-	server = StartServer(codeChan)
+// CallbackResult holds the query parameters of the OAuth redirect.
+type CallbackResult struct {
+	Code             string
+	State            string
+	Error            string
+	ErrorDescription string
+
+	// bareCode is set for a code pasted without its redirect URL, which
+	// carries no state to validate.
+	bareCode bool
+}
+
+func (r CallbackResult) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if r.ErrorDescription != "" {
+		return fmt.Errorf("authorization failed: %s: %s", r.Error, r.ErrorDescription)
+	}
+	return fmt.Errorf("authorization failed: %s", r.Error)
+}
+
+func GetToken(opts LoginOptions) (*oauth2.Token, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultLoginTimeout
+	}
+
+	if opts.RedirectURL == "" {
+		if opts.Port == 0 {
+			opts.Port = DefaultCallbackPort
+		}
+		opts.RedirectURL = DefaultRedirectURL(opts.Port)
+	}
+	config.RedirectURL = opts.RedirectURL
+
+	resultChan := make(chan CallbackResult, 1)
+
+	var err error
+	server, err = StartServer(resultChan, opts.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
 	defer ShutdownServer()
 
 	clientId := ""
@@ -68,48 +160,214 @@ func GetToken() (*oauth2.Token, error) {
 	config.ClientID = clientId
 	config.ClientSecret = clientSecret
 
-	codeVerifier, codeChallenge := GenerateCodeVerifierAndChallenge()
+	state, err := GenerateState()
+	if err != nil {
+		return nil, err
+	}
+
+	codeVerifier, codeChallenge, err := GenerateCodeVerifierAndChallenge(provider.PKCEMethod)
+	if err != nil {
+		return nil, err
+	}
 
-	url := config.AuthCodeURL("state", oauth2.SetAuthURLParam("code_challenge", codeChallenge))
+	url := config.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", provider.PKCEMethod),
+	)
 
 	fmt.Printf("Please visit the following URL to login: \n%s\n", url)
-	fmt.Println("After successful login, please enter the code here: ")
 
-	if err := browser.OpenURL(url); err != nil {
-		panic(fmt.Errorf("failed to open browser for authentication %s", err.Error()))
+	// Pasting is offered when there is no browser to redirect back from.
+	// Otherwise stdin is left alone, a reader blocked on it can't be stopped
+	// and would swallow input after the login.
+	paste := opts.NoBrowser
+	if !opts.NoBrowser {
+		if err := browser.OpenURL(url); err != nil {
+			fmt.Printf("Could not open a browser (%v), please open the URL above manually.\n", err)
+			paste = true
+		}
+	}
+
+	var in io.Reader
+	if paste {
+		in = os.Stdin
+		fmt.Println("After successful login, please wait for the redirect or paste the redirect URL or code here: ")
+	} else {
+		fmt.Println("After successful login, please wait for the redirect.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	var result CallbackResult
+	select {
+	case result = <-waitForCallback(ctx, resultChan, in):
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out after %s waiting for login", opts.Timeout)
+	}
+	cancel()
+	ShutdownServer()
+
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	if !result.bareCode && result.State != state {
+		return nil, errors.New("authorization failed: state mismatch, please try logging in again")
+	}
+	if result.Code == "" {
+		return nil, errors.New("authorization failed: no code received")
 	}
-	code := <-codeChan
 
-	return ExchangeAuthorizationCodeForToken(config, code, codeVerifier)
+	return ExchangeAuthorizationCodeForToken(config, result.Code, codeVerifier)
 }
 
-func StartServer(codeChan chan string) *http.Server {
-	server := &http.Server{Addr: ":9999"}
-	http.HandleFunc("/oauth/callback", HandleOAuthCallback(codeChan))
+// waitForCallback returns the first result received either from the callback
+// server or from a line pasted on in, which may be nil. Its goroutines stop
+// once ctx is done, the one reading in after its next line.
+func waitForCallback(ctx context.Context, resultChan chan CallbackResult, in io.Reader) <-chan CallbackResult {
+	result := make(chan CallbackResult, 2)
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+		select {
+		case r := <-resultChan:
+			result <- r
+		case <-ctx.Done():
 		}
 	}()
-	return server
+
+	if in == nil {
+		return result
+	}
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if ctx.Err() != nil {
+				return
+			}
+			r := ParseCallbackInput(scanner.Text())
+			if r.Code != "" || r.Error != "" {
+				result <- r
+				return
+			}
+			fmt.Println("No code found in input, please paste the full redirect URL or the code: ")
+		}
+	}()
+
+	return result
 }
 
-func ShutdownServer() {
-	if server != nil {
-		if err := server.Shutdown(context.Background()); err != nil {
-			log.Fatalf("Failed to shut down server: %v", err)
+// ParseCallbackInput extracts the redirect parameters from a pasted URL such
+// as http://localhost:9999/oauth/callback?code=...&state=... and treats any
+// other single token as a bare code.
+func ParseCallbackInput(input string) CallbackResult {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return CallbackResult{}
+	}
+
+	if strings.Contains(input, "code=") || strings.Contains(input, "error=") {
+		query := input
+		if u, err := url.Parse(input); err == nil && u.RawQuery != "" {
+			query = u.RawQuery
+		}
+		values, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+		if err != nil {
+			return CallbackResult{}
 		}
+		return callbackResultFromQuery(values)
+	}
+
+	if strings.ContainsAny(input, " /?&") {
+		return CallbackResult{}
 	}
+	return CallbackResult{Code: input, bareCode: true}
 }
 
-func HandleOAuthCallback(codeChan chan string) http.HandlerFunc {
+func callbackResultFromQuery(values url.Values) CallbackResult {
+	return CallbackResult{
+		Code:             values.Get("code"),
+		State:            values.Get("state"),
+		Error:            values.Get("error"),
+		ErrorDescription: values.Get("error_description"),
+	}
+}
+
+// StartServer listens for the OAuth redirect on the loopback address of
+// redirectURL. It fails straight away if the port is already taken.
+func StartServer(resultChan chan CallbackResult, redirectURL string) (*http.Server, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI %q: %w", redirectURL, err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("redirect URI %q must use http", redirectURL)
+	}
+
+	var host string
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1":
+		host = "127.0.0.1"
+	case "::1":
+		host = "::1"
+	default:
+		return nil, fmt.Errorf("redirect URI %q must point at localhost", redirectURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	addr := net.JoinHostPort(host, port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start login callback server on %s, is another login running or the port in use? "+
+			"Pick another with --port and add the matching redirect URL to your MAL app: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, HandleOAuthCallback(resultChan))
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Login callback server stopped: %v", err)
+		}
+	}()
+	return server, nil
+}
+
+func ShutdownServer() {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	server = nil
+}
+
+func HandleOAuthCallback(resultChan chan CallbackResult) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		queryParts := r.URL.Query()
+		result := callbackResultFromQuery(r.URL.Query())
 
-		code := queryParts.Get("code")
+		// Only the first redirect counts, a reload must not block the handler.
+		select {
+		case resultChan <- result:
+		default:
+		}
 
-		codeChan <- code
+		if err := result.Err(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<p><strong>Authentication failed</strong>: %s</p>", html.EscapeString(err.Error()))
+			return
+		}
 
 		msg := "<p><strong>Authentication successful</strong>. You may now close this tab.</p>"
 		fmt.Fprint(w, msg)
@@ -125,7 +383,82 @@ func ExchangeAuthorizationCodeForToken(config *oauth2.Config, code, codeVerifier
 	values.Set("code_verifier", codeVerifier)
 	values.Set("grant_type", "authorization_code")
 
-	resp, err := http.PostForm(config.Endpoint.TokenURL, values)
+	return requestToken(config.Endpoint.TokenURL, values)
+}
+
+// RefreshCredentials trades the refresh token for a new token pair and
+// updates creds in place.
+func RefreshCredentials(creds *Credentials) error {
+	if creds.Token.RefreshToken == "" {
+		return errors.New("no refresh token stored, please log in again")
+	}
+	if creds.ClientID == "" {
+		return errors.New("client ID was not stored with this login, please log in again")
+	}
+
+	values := url.Values{}
+	values.Set("client_id", creds.ClientID)
+	values.Set("client_secret", creds.ClientSecret)
+	values.Set("refresh_token", creds.Token.RefreshToken)
+	values.Set("grant_type", "refresh_token")
+
+	token, err := requestToken(provider.TokenURL, values)
+	if err != nil {
+		return err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = creds.Token.RefreshToken
+	}
+
+	creds.Token = token
+	return nil
+}
+
+// RevokeCredentials invalidates the stored token with the provider. It
+// returns ErrRevocationUnsupported when the provider has no revocation
+// endpoint.
+func RevokeCredentials(creds *Credentials) error {
+	if provider.RevokeURL == "" {
+		return ErrRevocationUnsupported
+	}
+
+	values := url.Values{}
+	values.Set("client_id", creds.ClientID)
+	values.Set("client_secret", creds.ClientSecret)
+	values.Set("token", creds.Token.AccessToken)
+
+	resp, err := http.PostForm(provider.RevokeURL, values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// NewCredentials pairs token with the client of the current login.
+func NewCredentials(token *oauth2.Token) *Credentials {
+	return &Credentials{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Scopes:       config.Scopes,
+		Token:        token,
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func requestToken(tokenURL string, values url.Values) (*oauth2.Token, error) {
+	resp, err := http.PostForm(tokenURL, values)
 	if err != nil {
 		return nil, err
 	}
@@ -135,63 +468,63 @@ func ExchangeAuthorizationCodeForToken(config *oauth2.Config, code, codeVerifier
 		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
 	}
 
-	var token oauth2.Token
-	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
 		return nil, err
 	}
 
-	return &token, nil
+	token := &oauth2.Token{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		RefreshToken: tr.RefreshToken,
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
 }
 
 func ReadAccessTokenFromFile(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	creds, err := (&FileStore{Path: filePath}).Load()
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
 
-	var tokenData map[string]interface{}
-	if err := json.NewDecoder(file).Decode(&tokenData); err != nil {
-		return "", err
-	}
-	accessToken, ok := tokenData["access_token"].(string)
-	if !ok {
-		return "", errors.New("access token not found or not a string")
-	}
-
-	return accessToken, nil
+	return creds.Token.AccessToken, nil
 }
 
 func WriteTokenToFile(token *oauth2.Token, filePath string) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return (&FileStore{Path: filePath}).Save(NewCredentials(token))
+}
 
-	tokenData := map[string]interface{}{
-		"access_token":  token.AccessToken,
-		"refresh_token": token.RefreshToken,
-		"expiry":        token.Expiry,
+// GenerateCodeVerifierAndChallenge returns a PKCE code verifier and the
+// challenge for method, which is either PKCEMethodPlain or PKCEMethodS256.
+func GenerateCodeVerifierAndChallenge(method string) (string, string, error) {
+	randomBytes := make([]byte, 64)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(tokenData); err != nil {
-		return err
+	// 64 bytes encode to 86 characters, within the 43-128 allowed by RFC 7636
+	codeVerifier := base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	switch method {
+	case PKCEMethodPlain:
+		return codeVerifier, codeVerifier, nil
+	case PKCEMethodS256:
+		sum := sha256.Sum256([]byte(codeVerifier))
+		return codeVerifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+	default:
+		return "", "", fmt.Errorf("unsupported PKCE method %q", method)
 	}
-
-	return nil
 }
 
-func GenerateCodeVerifierAndChallenge() (string, string) {
-	randomBytes := make([]byte, 64)
+func GenerateState() (string, error) {
+	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
-		log.Fatal("Failed to generate random bytes:", err)
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
 
-	codeVerifier := base64.URLEncoding.EncodeToString(randomBytes)
-	codeVerifier = codeVerifier[:len(codeVerifier)-2]
-
-	return codeVerifier, codeVerifier
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
 }
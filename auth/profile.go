@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const DefaultProfile = "default"
+
+var (
+	profile = DefaultProfile
+
+	profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// SetProfile selects which stored login the following calls use. Each
+// profile has its own token file, the default one keeps the original
+// ~/.anitrack.conf location.
+func SetProfile(name string) error {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, '-' and '_' only", name)
+	}
+
+	profile = name
+	return nil
+}
+
+func Profile() string {
+	return profile
+}
+
+// profileFileName turns a base name like ".anitrack.conf" into
+// ".anitrack.<profile>.conf" for non-default profiles.
+func profileFileName(base string) string {
+	if profile == DefaultProfile {
+		return base
+	}
+
+	i := strings.LastIndex(base, ".")
+	if i <= 0 {
+		return base + "." + profile
+	}
+	return base[:i] + "." + profile + base[i:]
+}
+
+// ListProfiles returns the profiles with a stored login, sorted by name.
+func ListProfiles() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(homeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, base := range []string{AnitrackTokenFileName, AnitrackEncryptedTokenFileName} {
+		ext := filepath.Ext(base)
+		prefix := strings.TrimSuffix(base, ext) + "."
+		for _, e := range entries {
+			name := e.Name()
+			switch {
+			case e.IsDir():
+			case name == base:
+				found[DefaultProfile] = true
+			case strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext):
+				if p := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext); profileNamePattern.MatchString(p) {
+					found[p] = true
+				}
+			}
+		}
+	}
+
+	profiles := make([]string, 0, len(found))
+	for p := range found {
+		profiles = append(profiles, p)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	AnitrackEncryptedTokenFileName = ".anitrack.enc"
+
+	passphraseEnv = "ANITRACK_PASSPHRASE"
+
+	kdfIterations = 210000
+	kdfSaltSize   = 16
+)
+
+var ErrNotLoggedIn = errors.New("no stored token found, run `ani-track login` first")
+
+// Credentials is everything needed to call the API and refresh the token
+// without logging in again.
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Token        *oauth2.Token
+}
+
+// CredentialStore persists the credentials of one profile between runs.
+type CredentialStore interface {
+	Load() (*Credentials, error)
+	Save(creds *Credentials) error
+	Delete() error
+	// Location describes where the credentials are kept.
+	Location() string
+}
+
+// NewCredentialStore returns the encrypted store when ANITRACK_PASSPHRASE is
+// set and the plain file store otherwise.
+func NewCredentialStore() (CredentialStore, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		return &EncryptedStore{
+			Path:       filepath.Join(homeDir, profileFileName(AnitrackEncryptedTokenFileName)),
+			Passphrase: passphrase,
+		}, nil
+	}
+
+	tokenFile, err := GetTokenFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{Path: tokenFile}, nil
+}
+
+type tokenData struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+	ClientID     string    `json:"client_id,omitempty"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Scopes       []string  `json:"scopes,omitempty"`
+}
+
+func newTokenData(creds *Credentials) tokenData {
+	return tokenData{
+		AccessToken:  creds.Token.AccessToken,
+		RefreshToken: creds.Token.RefreshToken,
+		Expiry:       creds.Token.Expiry,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		Scopes:       creds.Scopes,
+	}
+}
+
+func (d tokenData) credentials() (*Credentials, error) {
+	if d.AccessToken == "" {
+		return nil, errors.New("access token not found or not a string")
+	}
+	return &Credentials{
+		ClientID:     d.ClientID,
+		ClientSecret: d.ClientSecret,
+		Scopes:       d.Scopes,
+		Token: &oauth2.Token{
+			AccessToken:  d.AccessToken,
+			TokenType:    "Bearer",
+			RefreshToken: d.RefreshToken,
+			Expiry:       d.Expiry,
+		},
+	}, nil
+}
+
+type FileStore struct {
+	Path string
+}
+
+func (s *FileStore) Load() (*Credentials, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var td tokenData
+	if err := json.Unmarshal(data, &td); err != nil {
+		return nil, err
+	}
+	return td.credentials()
+}
+
+func (s *FileStore) Save(creds *Credentials) error {
+	data, err := json.MarshalIndent(newTokenData(creds), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, append(data, '\n'))
+}
+
+func (s *FileStore) Delete() error {
+	return removeIfExists(s.Path)
+}
+
+func (s *FileStore) Location() string {
+	return s.Path
+}
+
+// EncryptedStore keeps the token encrypted with AES-GCM under a key derived
+// from Passphrase with PBKDF2-SHA256.
+type EncryptedStore struct {
+	Path       string
+	Passphrase string
+}
+
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (s *EncryptedStore) Load() (*Credentials, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ef encryptedFile
+	if err := json.Unmarshal(data, &ef); err != nil {
+		return nil, err
+	}
+	if ef.Version != 1 {
+		return nil, fmt.Errorf("unsupported encrypted token file version %d", ef.Version)
+	}
+
+	gcm, err := newGCM(s.Passphrase, ef.Salt, ef.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, ef.Nonce, ef.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt token file, wrong passphrase?")
+	}
+
+	var td tokenData
+	if err := json.Unmarshal(plaintext, &td); err != nil {
+		return nil, err
+	}
+	return td.credentials()
+}
+
+func (s *EncryptedStore) Save(creds *Credentials) error {
+	plaintext, err := json.Marshal(newTokenData(creds))
+	if err != nil {
+		return err
+	}
+
+	ef := encryptedFile{
+		Version:    1,
+		Iterations: kdfIterations,
+		Salt:       make([]byte, kdfSaltSize),
+	}
+	if _, err := rand.Read(ef.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(s.Passphrase, ef.Salt, ef.Iterations)
+	if err != nil {
+		return err
+	}
+	ef.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ef.Nonce); err != nil {
+		return err
+	}
+	ef.Ciphertext = gcm.Seal(nil, ef.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(ef, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, append(data, '\n'))
+}
+
+func (s *EncryptedStore) Delete() error {
+	return removeIfExists(s.Path)
+}
+
+func (s *EncryptedStore) Location() string {
+	return s.Path + " (encrypted)"
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	key := pbkdf2SHA256([]byte(passphrase), salt, iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 implements RFC 8018 PBKDF2 with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	key := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// writeFileAtomic writes data to a temp file next to path with mode 0600 and
+// renames it into place, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
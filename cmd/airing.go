@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rinem/ani-track/airing"
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+// gridCellWidth is the width of one day column in the --week grid.
+const gridCellWidth = 22
+
+func AiringCmd() *cobra.Command {
+	var source, username, format, status string
+	var weekGrid bool
+
+	cmd := &cobra.Command{
+		Use:   "airing",
+		Short: "Show when the next episodes of the airing anime on your list come out",
+		Long: `Show when the next episodes of the airing anime on your list come out.
+
+Broadcast times are taken from MAL (in JST) and converted to your local time
+zone. Episodes are assumed to air weekly from the start date, so shows that
+skip a week will be reported one episode ahead until MAL's data catches up.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+			if status != "" && !validStatus(status) {
+				log.Fatalf("invalid status %q, use one of %v", status, animeListStatuses)
+			}
+
+			list, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if status != "" {
+				list = filterByStatus(list, status)
+			}
+
+			now := time.Now()
+			shows, skipped := airing.Schedule(list, now)
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, shows); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			if len(shows) == 0 {
+				fmt.Println("Nothing on your list is airing right now.")
+			} else if weekGrid {
+				printWeekGrid(os.Stdout, shows, now)
+			} else {
+				printNewToday(os.Stdout, shows, now)
+				printAiring(os.Stdout, shows, now)
+			}
+			if len(skipped) > 0 {
+				fmt.Fprintf(os.Stderr, "\nNo broadcast schedule for: %s\n", joinTitles(skipped))
+			}
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addOutputFlag(cmd, &format)
+	cmd.Flags().StringVarP(&status, "status", "s", "watching", "Only include entries with this list status, empty for all")
+	cmd.Flags().BoolVar(&weekGrid, "week", false, "Show the coming seven days as a calendar grid")
+	cmd.RegisterFlagCompletionFunc("status", completeChoices(animeListStatuses...))
+
+	return cmd
+}
+
+func filterByStatus(list []api.AnimeListEntry, status string) []api.AnimeListEntry {
+	var filtered []api.AnimeListEntry
+	for _, entry := range list {
+		if entry.ListStatus.Status == status {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func printNewToday(w io.Writer, shows []*airing.Show, now time.Time) {
+	var lines []string
+	for _, s := range shows {
+		for _, n := range s.EpisodesBetween(startOfDay(now), now.Add(time.Second)) {
+			lines = append(lines, fmt.Sprintf("  %s  %s episode %d", s.Episode(n).Local().Format("15:04"), s.Anime.Title, n))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "New today:")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w)
+}
+
+func printAiring(w io.Writer, shows []*airing.Show, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tNEXT EPISODE\tIN\tAIRED\tWATCHED\tBACKLOG")
+	for _, s := range shows {
+		next, in := "finished", "-"
+		if !s.NextEpisode.IsZero() {
+			next = fmt.Sprintf("#%d %s", s.NextNumber, s.NextEpisode.Local().Format("Mon 02 Jan 15:04"))
+			in = formatDuration(s.NextEpisode.Sub(now))
+		}
+		aired := strconv.Itoa(s.Aired)
+		if s.Anime.NumEpisodes > 0 {
+			aired += "/" + strconv.Itoa(s.Anime.NumEpisodes)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Anime.ID, s.Anime.Title, next, in, aired, s.Watched, dashIfZero(s.Backlog))
+	}
+	tw.Flush()
+}
+
+// printWeekGrid prints one column per day starting today, each listing the
+// episodes airing that day in local time.
+func printWeekGrid(w io.Writer, shows []*airing.Show, now time.Time) {
+	today := startOfDay(now)
+	var columns [7][]string
+	rows := 0
+	for day := range columns {
+		from, to := today.AddDate(0, 0, day), today.AddDate(0, 0, day+1)
+		for _, s := range shows {
+			for _, n := range s.EpisodesBetween(from, to) {
+				columns[day] = append(columns[day], fmt.Sprintf("%s #%d %s", s.Episode(n).Local().Format("15:04"), n, s.Anime.Title))
+			}
+		}
+		sort.Strings(columns[day])
+		if len(columns[day]) > rows {
+			rows = len(columns[day])
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for day := range columns {
+		fmt.Fprint(tw, today.AddDate(0, 0, day).Format("Mon 02 Jan"), "\t")
+	}
+	fmt.Fprintln(tw)
+	for row := 0; row < rows; row++ {
+		for day := range columns {
+			cell := ""
+			if row < len(columns[day]) {
+				cell = truncate(columns[day][row], gridCellWidth)
+			}
+			fmt.Fprint(tw, cell, "\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+func joinTitles(titles []string) string {
+	return strings.Join(titles, ", ")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+func AnimeCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:               "anime [anime]",
+		Short:             "Show the details of an anime",
+		Long:              "Show the details of an anime.\n\n" + animeArgHelp,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeAnime,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+			anime, err := resolveAnime(strings.Join(args, " "))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			details, err := api.GetAnimeDetails(anime.ID, api.AnimeDetailFields, accessToken)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, details); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if err := printAnimeDetails(os.Stdout, details); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addOutputFlag(cmd, &format)
+
+	return cmd
+}
+
+func animeURL(id int) string {
+	return fmt.Sprintf("https://myanimelist.net/anime/%d", id)
+}
+
+// animeLabel names an anime with enough context to tell remakes and
+// same-titled entries apart.
+func animeLabel(a api.Anime) string {
+	var details []string
+	if a.MediaType != "" {
+		details = append(details, strings.ToUpper(a.MediaType))
+	}
+	if year := animeYear(a); year != "" {
+		details = append(details, year)
+	}
+	if a.NumEpisodes > 0 {
+		details = append(details, fmt.Sprintf("%d eps", a.NumEpisodes))
+	}
+
+	label := a.Title
+	if len(details) > 0 {
+		label += " (" + strings.Join(details, ", ") + ")"
+	}
+	label += fmt.Sprintf(" #%d", a.ID)
+	if a.MyListStatus != nil {
+		label += " [" + a.MyListStatus.Status + "]"
+	}
+	return label
+}
+
+func printAnimeDetails(w io.Writer, a *api.Anime) error {
+	fmt.Fprintf(w, "%s (#%d)\n\n", a.Title, a.ID)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", label, value)
+		}
+	}
+
+	row("English", a.AlternativeTitles.En)
+	row("Japanese", a.AlternativeTitles.Ja)
+	row("Synonyms", strings.Join(a.AlternativeTitles.Synonyms, ", "))
+
+	var kind []string
+	if a.MediaType != "" {
+		kind = append(kind, strings.ToUpper(a.MediaType))
+	}
+	if a.NumEpisodes > 0 {
+		kind = append(kind, fmt.Sprintf("%d episodes", a.NumEpisodes))
+	}
+	if a.Status != "" {
+		kind = append(kind, strings.ReplaceAll(a.Status, "_", " "))
+	}
+	row("Type", strings.Join(kind, ", "))
+
+	aired := a.StartDate
+	if a.EndDate != "" {
+		aired += " to " + a.EndDate
+	}
+	if a.StartSeason != nil {
+		aired += fmt.Sprintf(" (%s %d)", a.StartSeason.Season, a.StartSeason.Year)
+	}
+	row("Aired", strings.TrimSpace(aired))
+	if a.Broadcast != nil && a.Broadcast.DayOfTheWeek != "" {
+		row("Broadcast", fmt.Sprintf("%s %s JST", a.Broadcast.DayOfTheWeek, a.Broadcast.StartTime))
+	}
+	if a.Mean > 0 {
+		row("Score", fmt.Sprintf("%.2f (rank #%d, popularity #%d)", a.Mean, a.Rank, a.Popularity))
+	}
+
+	genres := make([]string, len(a.Genres))
+	for i, g := range a.Genres {
+		genres[i] = g.Name
+	}
+	row("Genres", strings.Join(genres, ", "))
+	row("Studios", studioNames(*a))
+	row("Source", strings.ReplaceAll(a.Source, "_", " "))
+	row("Rating", a.Rating)
+
+	if st := a.MyListStatus; st != nil {
+		mine := fmt.Sprintf("%s, %s episodes", st.Status, formatProgress(st.NumEpisodesWatched, a.NumEpisodes))
+		if st.Score > 0 {
+			mine += fmt.Sprintf(", scored %d", st.Score)
+		}
+		row("My list", mine)
+	} else {
+		row("My list", "not listed")
+	}
+	row("URL", animeURL(a.ID))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if a.Synopsis != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(a.Synopsis))
+	}
+	return nil
+}
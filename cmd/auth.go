@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/auth"
+	"github.com/spf13/cobra"
+)
+
+// refreshMargin refreshes tokens slightly before they expire so a request
+// started right before expiry does not fail.
+const refreshMargin = time.Minute
+
+func AuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "auth",
+		Short:       "Inspect and manage the stored MyAnimeList login",
+		Annotations: map[string]string{skipQueueFlush: "true"},
+	}
+
+	cmd.AddCommand(authStatusCmd(), authRefreshCmd())
+
+	return cmd
+}
+
+func authStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the logged in account and token expiry",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := auth.NewCredentialStore()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("Profile:   %s\n", auth.Profile())
+			fmt.Printf("Stored in: %s\n", store.Location())
+
+			creds, err := store.Load()
+			if errors.Is(err, auth.ErrNotLoggedIn) {
+				fmt.Println("Status:    not logged in")
+				return
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			expired := !creds.Token.Expiry.IsZero() && time.Now().After(creds.Token.Expiry)
+			if expired {
+				fmt.Println("Username:  unknown (token expired, run `ani-track auth refresh`)")
+			} else if user, err := api.GetMyUserInfo(creds.Token.AccessToken); err != nil {
+				fmt.Printf("Username:  unknown (%v)\n", err)
+			} else {
+				fmt.Printf("Username:  %s\n", user.Name)
+			}
+
+			fmt.Printf("Client ID: %s\n", maskSecret(creds.ClientID))
+			fmt.Printf("Scopes:    %s\n", strings.Join(creds.Scopes, " "))
+			fmt.Printf("Expires:   %s\n", describeExpiry(creds.Token.Expiry))
+			fmt.Printf("Refresh:   %t\n", creds.Token.RefreshToken != "")
+		},
+	}
+}
+
+func authRefreshCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Rotate the stored access token using the refresh token",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := auth.NewCredentialStore()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			creds, err := store.Load()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := auth.RefreshCredentials(creds); err != nil {
+				log.Fatal(err)
+			}
+
+			if err := store.Save(creds); err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("Token refreshed. Expires: %s\n", describeExpiry(creds.Token.Expiry))
+		},
+	}
+}
+
+func LogoutCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "logout",
+		Short:       "Delete the stored MyAnimeList login",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := auth.NewCredentialStore()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			creds, err := store.Load()
+			if errors.Is(err, auth.ErrNotLoggedIn) {
+				fmt.Println("Not logged in.")
+				return
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = auth.RevokeCredentials(creds)
+			switch {
+			case errors.Is(err, auth.ErrRevocationUnsupported):
+				fmt.Println("MyAnimeList cannot revoke tokens, the old token stays valid until it expires.")
+				fmt.Println("Delete the app from your MAL API settings to revoke it right away.")
+			case err != nil:
+				fmt.Printf("Failed to revoke token: %v\n", err)
+			}
+
+			if err := store.Delete(); err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("Logged out of profile %s.\n", auth.Profile())
+		},
+	}
+}
+
+// tokenMu serializes readAccessToken for the servers, which handle requests
+// concurrently. MAL rotates refresh tokens, so only the first of two
+// concurrent refreshes would succeed; the second caller now loads the token
+// the first one saved instead.
+var tokenMu sync.Mutex
+
+// readAccessToken loads the stored token, refreshing and saving it first
+// when it is about to expire.
+func readAccessToken() (string, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	store, err := auth.NewCredentialStore()
+	if err != nil {
+		return "", err
+	}
+
+	creds, err := store.Load()
+	if err != nil {
+		return "", err
+	}
+
+	expiry := creds.Token.Expiry
+	if !cacheOptions.Offline && !expiry.IsZero() && time.Now().Add(refreshMargin).After(expiry) && creds.Token.RefreshToken != "" {
+		if err := auth.RefreshCredentials(creds); err != nil {
+			return "", fmt.Errorf("access token expired and refreshing it failed, please log in again: %w", err)
+		}
+		if err := store.Save(creds); err != nil {
+			return "", err
+		}
+	}
+
+	return creds.Token.AccessToken, nil
+}
+
+func describeExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "unknown"
+	}
+
+	left := time.Until(expiry)
+	if left <= 0 {
+		return fmt.Sprintf("%s (expired)", expiry.Local().Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("%s (in %s)", expiry.Local().Format("2006-01-02 15:04"), formatDuration(left))
+}
+
+func formatDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, int(d.Minutes())%60)
+}
+
+func maskSecret(s string) string {
+	if s == "" {
+		return "not stored"
+	}
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rinem/ani-track/airing"
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+func CalendarCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "calendar",
+		Short: "Export the airing anime on your list as an iCalendar feed",
+	}
+
+	cmd.AddCommand(calendarExportCmd(), calendarServeCmd())
+
+	return cmd
+}
+
+func calendarExportCmd() *cobra.Command {
+	var source, username, outFile string
+	var statuses []string
+	var ics bool
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write an .ics file with a weekly event per airing anime",
+		Long: `Write an .ics file with a weekly event per airing anime.
+
+Each show gets one recurring event at its broadcast time, converted from JST,
+that ends after the announced number of episodes. Import the file into any
+calendar app, or use ` + "`calendar serve`" + ` to subscribe to it instead.`,
+		Example: `  ani-track calendar export --ics > airing.ics
+  ani-track calendar export --ics --out airing.ics --status watching`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !ics {
+				log.Fatal("iCalendar is the only supported export format, drop --ics=false")
+			}
+
+			data, skipped, err := buildCalendar(source, username, statuses)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if outFile == "" {
+				_, err = os.Stdout.Write(data)
+			} else {
+				err = os.WriteFile(outFile, data, 0644)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if outFile != "" {
+				fmt.Printf("Wrote %s\n", outFile)
+			}
+			if len(skipped) > 0 {
+				fmt.Fprintf(os.Stderr, "No broadcast schedule for: %s\n", joinTitles(skipped))
+			}
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addCalendarStatusFlag(cmd, &statuses)
+	cmd.Flags().BoolVar(&ics, "ics", true, "Export as RFC 5545 iCalendar")
+	cmd.Flags().StringVar(&outFile, "out", "", "Write the calendar to this file instead of stdout")
+
+	return cmd
+}
+
+func calendarServeCmd() *cobra.Command {
+	var source, username, addr string
+	var statuses []string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the airing calendar over HTTP for calendar apps to subscribe to",
+		Long: `Serve the airing calendar over HTTP for calendar apps to subscribe to.
+
+The feed is rebuilt on every request from your list, which is fetched through
+the response cache, so it follows your list without hammering MAL.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Requests are served concurrently, but the list fetch goes
+			// through the token and response cache files, so one at a time.
+			var mu sync.Mutex
+			mux := http.NewServeMux()
+			mux.HandleFunc("/calendar.ics", func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				data, _, err := buildCalendar(source, username, statuses)
+				mu.Unlock()
+				if err != nil {
+					log.Printf("building calendar: %v", err)
+					http.Error(w, "failed to build calendar", http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+				w.Header().Set("Cache-Control", "max-age=300")
+				http.ServeContent(w, r, "calendar.ics", time.Time{}, bytes.NewReader(data))
+			})
+
+			fmt.Printf("Serving the airing calendar at http://%s/calendar.ics\n", addr)
+			log.Fatal(http.ListenAndServe(addr, mux))
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addCalendarStatusFlag(cmd, &statuses)
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8642", "Address to listen on")
+
+	return cmd
+}
+
+func addCalendarStatusFlag(cmd *cobra.Command, statuses *[]string) {
+	cmd.Flags().StringSliceVarP(statuses, "status", "s", []string{"watching", "plan_to_watch"},
+		"Only include entries with these list statuses, empty for all")
+	cmd.RegisterFlagCompletionFunc("status", completeChoices(animeListStatuses...))
+}
+
+func buildCalendar(source, username string, statuses []string) ([]byte, []string, error) {
+	for _, status := range statuses {
+		if !validStatus(status) {
+			return nil, nil, fmt.Errorf("invalid status %q, use one of %v", status, animeListStatuses)
+		}
+	}
+
+	list, err := loadAnimeEntries(source, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(statuses) > 0 {
+		var filtered []api.AnimeListEntry
+		for _, status := range statuses {
+			filtered = append(filtered, filterByStatus(list, status)...)
+		}
+		list = filtered
+	}
+
+	now := time.Now()
+	shows, skipped := airing.Schedule(list, now)
+
+	var buf bytes.Buffer
+	if err := airing.WriteICS(&buf, shows, now); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), skipped, nil
+}
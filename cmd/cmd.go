@@ -10,21 +10,24 @@ import (
 )
 
 func LoginCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "login",
-		Short: "Perform OAuth login to MyAnimeList",
+	var opts auth.LoginOptions
+
+	cmd := &cobra.Command{
+		Use:         "login",
+		Short:       "Perform OAuth login to MyAnimeList",
+		Annotations: map[string]string{skipQueueFlush: "true"},
 		Run: func(cmd *cobra.Command, args []string) {
-			tokenFile, err := auth.GetTokenFilePath()
+			store, err := auth.NewCredentialStore()
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			token, err := auth.GetToken()
+			token, err := auth.GetToken(opts)
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			if err := auth.WriteTokenToFile(token, tokenFile); err != nil {
+			if err := store.Save(auth.NewCredentials(token)); err != nil {
 				log.Fatal(err)
 			}
 
@@ -34,41 +37,11 @@ func LoginCmd() *cobra.Command {
 			auth.ShutdownServer()
 		},
 	}
-}
-
-func SearchCmd() *cobra.Command {
-	var limit string
-
-	cmd := &cobra.Command{
-		Use:   "search [query]",
-		Short: "Search for anime on MyAnimeList",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			query := args[0]
-
-			tokenFile, err := auth.GetTokenFilePath()
-			if err != nil {
-				log.Fatal(err)
-			}
 
-			accessToken, err := auth.ReadAccessTokenFromFile(tokenFile)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			result, err := api.SearchAnime(query, limit, accessToken)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			fmt.Println("Search Results:")
-			for _, anime := range result.Data {
-				fmt.Printf("%s\n", anime.Node.Title)
-			}
-		},
-	}
-
-	cmd.Flags().StringVarP(&limit, "limit", "l", "5", "Limit search results")
+	cmd.Flags().BoolVar(&opts.NoBrowser, "no-browser", false, "Print the login URL instead of opening a browser")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", auth.DefaultLoginTimeout, "How long to wait for the login to complete")
+	cmd.Flags().IntVar(&opts.Port, "port", auth.DefaultCallbackPort, "Local port for the login callback")
+	cmd.Flags().StringVar(&opts.RedirectURL, "redirect-uri", "", "Redirect URI registered in your MAL app, overrides --port")
 
 	return cmd
 }
@@ -84,12 +57,7 @@ func UserListCmd() *cobra.Command {
 
 			limit := cmd.Flag("limit").Value.String()
 
-			tokenFile, err := auth.GetTokenFilePath()
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			token, err := auth.ReadAccessTokenFromFile(tokenFile)
+			token, err := readAccessToken()
 			if err != nil {
 				log.Fatal(err)
 			}
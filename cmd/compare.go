@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/stats"
+	"github.com/spf13/cobra"
+)
+
+func CompareCmd() *cobra.Command {
+	var format string
+	var top int
+
+	cmd := &cobra.Command{
+		Use:   "compare [user1] [user2]",
+		Short: "Compare the anime lists and tastes of two users",
+		Args:  cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) >= 2 {
+				return nil, noFiles
+			}
+			return completeUsernames(cmd, args, toComplete)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			first, err := api.GetFullUserAnimeList(args[0], "id,title", accessToken)
+			if err != nil {
+				log.Fatalf("fetching %s's list: %v", args[0], err)
+			}
+			second, err := api.GetFullUserAnimeList(args[1], "id,title", accessToken)
+			if err != nil {
+				log.Fatalf("fetching %s's list: %v", args[1], err)
+			}
+
+			c := stats.Compare(first, second)
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, c); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			printComparison(os.Stdout, args[0], args[1], c, top)
+		},
+	}
+
+	addOutputFlag(cmd, &format)
+	cmd.Flags().IntVar(&top, "top", 15, "Number of rows to show per table")
+
+	return cmd
+}
+
+func printComparison(w io.Writer, first, second string, c stats.Comparison, top int) {
+	fmt.Fprintf(w, "Shared titles: %d (%d scored by both)\n", len(c.Shared), c.BothScored)
+	if c.Affinity == nil {
+		fmt.Fprintln(w, "Affinity: not enough titles scored by both")
+	} else {
+		fmt.Fprintf(w, "Affinity: %.1f%%   Pearson: %.3f   Spearman: %s\n", *c.Affinity, *c.Pearson, formatOptional(c.Spearman))
+	}
+
+	printShared(w, "Shared titles", first, second, limitTitles(c.Shared, top))
+
+	var disagreements []stats.SharedTitle
+	for _, t := range c.Shared {
+		if t.Diff() != 0 {
+			disagreements = append(disagreements, t)
+		}
+	}
+	sort.SliceStable(disagreements, func(i, j int) bool {
+		return abs(disagreements[i].Diff()) > abs(disagreements[j].Diff())
+	})
+	printShared(w, "Biggest disagreements", first, second, limitTitles(disagreements, top))
+
+	printShared(w, fmt.Sprintf("Completed by %s, planned by %s", first, second), first, second, limitTitles(c.ForSecond, top))
+	printShared(w, fmt.Sprintf("Completed by %s, planned by %s", second, first), first, second, limitTitles(c.ForFirst, top))
+}
+
+func printShared(w io.Writer, title, first, second string, titles []stats.SharedTitle) {
+	if len(titles) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "#\tTITLE\t%s\t%s\tDIFF\n", first, second)
+	for i, t := range titles {
+		diff := ""
+		if d := t.Diff(); d != 0 {
+			diff = fmt.Sprintf("%+d", d)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, t.Title,
+			describeListEntry(t.FirstStatus, t.FirstScore), describeListEntry(t.SecondStatus, t.SecondScore), diff)
+	}
+	tw.Flush()
+}
+
+func describeListEntry(status string, score int) string {
+	if score == 0 {
+		return status
+	}
+	return fmt.Sprintf("%d (%s)", score, status)
+}
+
+func limitTitles(titles []stats.SharedTitle, n int) []stats.SharedTitle {
+	if n > 0 && len(titles) > n {
+		return titles[:n]
+	}
+	return titles
+}
+
+func formatOptional(f *float64) string {
+	if f == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f", *f)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/auth"
+	"github.com/spf13/cobra"
+)
+
+const noFiles = cobra.ShellCompDirectiveNoFileComp
+
+// prepareCompletion applies the global flags like a normal run would, but
+// forces offline mode: completions must be instant and never wait on MAL,
+// so they only use the mirror and cached responses.
+func prepareCompletion() bool {
+	cacheOptions.Offline = true
+	return applyGlobalFlags() == nil
+}
+
+// completeChoices completes a flag or argument from a fixed list.
+func completeChoices(choices ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return cobra.FixedCompletions(choices, noFiles)
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, err := auth.ListProfiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return profiles, noFiles
+}
+
+// cachedList is the user's own list from the mirror or the response cache.
+func cachedList() []api.AnimeListEntry {
+	if !prepareCompletion() {
+		return nil
+	}
+	entries, err := loadAnimeEntries(sourceAuto, "@me")
+	if err != nil {
+		return nil
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		// Entries in progress are the likeliest targets.
+		wi, wj := entries[i].ListStatus.Status == "watching", entries[j].ListStatus.Status == "watching"
+		if wi != wj {
+			return wi
+		}
+		return entries[i].Node.Title < entries[j].Node.Title
+	})
+	return entries
+}
+
+// completeAnime completes the first argument of commands taking an anime
+// with the IDs on the user's list, described by their titles. Once a
+// title is being typed, matching titles are offered instead.
+func completeAnime(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, noFiles
+	}
+
+	_, numeric := strconv.Atoi(toComplete)
+	byID := toComplete == "" || numeric == nil
+	lower := strings.ToLower(toComplete)
+
+	var completions []string
+	for _, e := range cachedList() {
+		if byID {
+			if !strings.HasPrefix(strconv.Itoa(e.Node.ID), toComplete) {
+				continue
+			}
+			completions = append(completions, fmt.Sprintf("%d\t%s (%s)", e.Node.ID, e.Node.Title, e.ListStatus.Status))
+			continue
+		}
+		for _, title := range animeTitles(e.Node) {
+			if title != "" && strings.HasPrefix(strings.ToLower(title), lower) {
+				completions = append(completions, title)
+				break
+			}
+		}
+	}
+	return completions, noFiles
+}
+
+// completeWatched completes the anime and then the next episode to mark.
+func completeWatched(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 1 {
+		return completeAnime(cmd, args, toComplete)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	for _, e := range cachedList() {
+		if (err == nil && e.Node.ID == id) || strings.EqualFold(e.Node.Title, args[0]) {
+			next := e.ListStatus.NumEpisodesWatched + 1
+			if e.Node.NumEpisodes > 0 && next > e.Node.NumEpisodes {
+				return nil, noFiles
+			}
+			return []string{fmt.Sprintf("%d\tnext episode", next)}, noFiles
+		}
+	}
+	return nil, noFiles
+}
+
+// completeUsernames offers the logged in user and whoever's list is in the
+// mirror.
+func completeUsernames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	users := []string{"@me\tyourself"}
+	if !prepareCompletion() {
+		return users, noFiles
+	}
+	if m, err := openMirror(); err == nil {
+		if meta, err := m.Meta(); err == nil && meta.Username != "" && meta.Username != "@me" {
+			users = append(users, meta.Username+"\tlist in the local mirror")
+		}
+	}
+	return users, noFiles
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/franchise"
+	"github.com/spf13/cobra"
+)
+
+const (
+	franchiseFormatText    = "text"
+	franchiseFormatJSON    = "json"
+	franchiseFormatDOT     = "dot"
+	franchiseFormatMermaid = "mermaid"
+
+	orderChronological = "chronological"
+	orderRelease       = "release"
+)
+
+func FranchiseCmd() *cobra.Command {
+	var format, order string
+	var allRelations bool
+	var maxNodes int
+
+	cmd := &cobra.Command{
+		Use:   "franchise [anime]",
+		Short: "Show the related anime of a franchise and a watch order",
+		Long: `Show the related anime of a franchise and a watch order.
+
+Related anime are followed from the given title through sequels, prequels,
+side stories, spin-offs and alternative versions. Every title is fetched
+through the response cache, so walking a large franchise again is cheap.
+"character" and "other" relations are only followed with --all-relations.
+
+The graph can be exported with --format dot (Graphviz) or --format mermaid,
+titles on your list are coloured by their status.
+
+` + animeArgHelp,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeAnime,
+		Run: func(cmd *cobra.Command, args []string) {
+			switch format {
+			case franchiseFormatText, franchiseFormatJSON, franchiseFormatDOT, franchiseFormatMermaid:
+			default:
+				log.Fatalf("unknown format %q, expected text, json, dot or mermaid", format)
+			}
+			if order != orderChronological && order != orderRelease {
+				log.Fatalf("unknown order %q, expected chronological or release", order)
+			}
+
+			anime, err := resolveAnime(strings.Join(args, " "))
+			if err != nil {
+				log.Fatal(err)
+			}
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			opts := franchise.WalkOptions{MaxNodes: maxNodes}
+			if allRelations {
+				opts.Relations = append(append(opts.Relations, franchise.StoryRelations...), "character", "other")
+			}
+			fetch := func(id int) (*api.Anime, error) {
+				return api.GetAnimeDetails(id, franchise.FetchFields, accessToken)
+			}
+
+			g, err := franchise.Walk(anime.ID, fetch, opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if g.Truncated {
+				fmt.Fprintf(os.Stderr, "Stopped after %d titles, raise --max to see the rest.\n", maxNodes)
+			}
+
+			nodes := g.ChronologicalOrder()
+			if order == orderRelease {
+				nodes = g.ReleaseOrder()
+			}
+
+			switch format {
+			case franchiseFormatDOT:
+				err = franchise.WriteDOT(os.Stdout, g)
+			case franchiseFormatMermaid:
+				err = franchise.WriteMermaid(os.Stdout, g)
+			case franchiseFormatJSON:
+				err = printJSON(os.Stdout, struct {
+					*franchise.Graph
+					Order []*franchise.Node `json:"order"`
+				}{g, nodes})
+			default:
+				printWatchOrder(os.Stdout, g, nodes)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", franchiseFormatText, "Output format: text, json, dot or mermaid")
+	cmd.Flags().StringVar(&order, "order", orderChronological, "Watch order: chronological or release")
+	cmd.RegisterFlagCompletionFunc("format", completeChoices(franchiseFormatText, franchiseFormatJSON, franchiseFormatDOT, franchiseFormatMermaid))
+	cmd.RegisterFlagCompletionFunc("order", completeChoices(orderChronological, orderRelease))
+	cmd.Flags().BoolVar(&allRelations, "all-relations", false, `Also follow "character" and "other" relations`)
+	cmd.Flags().IntVar(&maxNodes, "max", 100, "Maximum number of titles to fetch, 0 for no limit")
+
+	return cmd
+}
+
+func printWatchOrder(w io.Writer, g *franchise.Graph, nodes []*franchise.Node) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tID\tTITLE\tTYPE\tEPISODES\tSTARTED\tRELATION\tMY STATUS")
+	for i, n := range nodes {
+		title := n.Anime.Title
+		if n.Anime.ID == g.Root {
+			title += " *"
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			i+1,
+			n.Anime.ID,
+			title,
+			dashIfEmpty(strings.ToUpper(n.Anime.MediaType)),
+			dashIfZero(n.Anime.NumEpisodes),
+			dashIfEmpty(n.Anime.StartDate),
+			dashIfEmpty(rootRelation(g, n.Anime.ID)),
+			dashIfEmpty(n.ListStatus()),
+		)
+	}
+	tw.Flush()
+}
+
+// rootRelation is how id relates to the root title, if they are linked
+// directly.
+func rootRelation(g *franchise.Graph, id int) string {
+	for _, e := range g.Edges {
+		if e.From == g.Root && e.To == id {
+			return strings.ReplaceAll(e.Relation, "_", " ")
+		}
+	}
+	return ""
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func dashIfZero(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/library"
+	"github.com/spf13/cobra"
+)
+
+const libraryTitleWidth = 40
+
+func LibraryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "library",
+		Short: "Match the anime files on disk to your list",
+	}
+
+	cmd.AddCommand(libraryScanCmd())
+
+	return cmd
+}
+
+func libraryScanCmd() *cobra.Command {
+	var source, username, format string
+	var minConfidence float64
+
+	cmd := &cobra.Command{
+		Use:   "scan <dir>",
+		Short: "Find the series in a directory and compare them with your list",
+		Long: `Find the series in a directory and compare them with your list.
+
+Video files are grouped into series by the title and season in their names,
+and each new series is matched to an anime by searching MyAnimeList (or
+the local mirror when offline). Matches are saved to a mapping file in the
+data directory and reused by later scans, so a wrong match can be fixed by
+setting its anime_id, and a series skipped with "ignore": true.
+
+The report lists the episodes on disk you haven't marked watched, and the
+series on disk that aren't on your list.`,
+		Example: `  ani-track library scan ~/Anime
+  ani-track library scan ~/Anime --min-confidence 0.8 -o json`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			series, err := library.Scan(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(series) == 0 {
+				log.Fatalf("no video files found in %s", args[0])
+			}
+
+			mapping, err := openLibraryMapping()
+			if err != nil {
+				log.Fatal(err)
+			}
+			matchSeries(series, mapping, minConfidence)
+			if err := mapping.Save(); err != nil {
+				log.Fatal(err)
+			}
+
+			entries, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+			list := make(map[int]api.AnimeListEntry, len(entries))
+			for _, e := range entries {
+				list[e.Node.ID] = e
+			}
+
+			var report []library.Progress
+			for _, s := range series {
+				entry := mapping.Series[s.Key]
+				if entry != nil && entry.Ignore {
+					continue
+				}
+				report = append(report, library.NewProgress(s, entry, list))
+			}
+
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, report); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			if err := printLibraryReport(os.Stdout, report, mapping); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addOutputFlag(cmd, &format)
+	cmd.Flags().Float64Var(&minConfidence, "min-confidence", 0.5, "Lowest match confidence, from 0 to 1, to accept without checking")
+
+	return cmd
+}
+
+func openLibraryMapping() (*library.Mapping, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return library.LoadMapping(filepath.Join(dir, library.MappingFileName))
+}
+
+// matchSeries searches an anime for every series not in the mapping yet,
+// or still unmatched, and records the result.
+func matchSeries(series []*library.Series, mapping *library.Mapping, minConfidence float64) {
+	for _, s := range series {
+		entry := mapping.Series[s.Key]
+		if entry != nil && (entry.AnimeID != 0 || entry.Ignore) {
+			continue
+		}
+
+		entry = &library.Entry{Title: s.Title, Dir: s.Dir}
+		mapping.Series[s.Key] = entry
+
+		candidates, _, err := titleCandidates(s.Query())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot match %s: %v\n", s.Query(), err)
+			continue
+		}
+		best, confidence, ok := library.BestMatch(s.Query(), candidates)
+
+		// Matches at less than half the threshold are too far off to be
+		// worth suggesting.
+		if !ok || confidence < minConfidence/2 {
+			continue
+		}
+		entry.AnimeTitle = best.Title
+		entry.Confidence = math.Round(confidence*100) / 100
+		if confidence >= minConfidence {
+			entry.AnimeID = best.ID
+			fmt.Fprintf(os.Stderr, "Matched %s to %s #%d (%.0f%%)\n", s.Query(), best.Title, best.ID, confidence*100)
+		} else {
+			entry.SuggestedID = best.ID
+		}
+	}
+}
+
+func printLibraryReport(w io.Writer, report []library.Progress, mapping *library.Mapping) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIES\tANIME\tMATCH\tON DISK\tLIST\tUNWATCHED")
+
+	var unwatched, series int
+	var notOnList []string
+	var unmatched []library.Progress
+	for _, p := range report {
+		anime, match, listed := "-", "-", "-"
+		switch {
+		case p.AnimeID == 0:
+			unmatched = append(unmatched, p)
+		case p.Status == "":
+			name := p.AnimeTitle
+			if name == "" {
+				name = p.Title
+			}
+			notOnList = append(notOnList, name)
+			listed = "not on list"
+		default:
+			listed = fmt.Sprintf("%s %s", p.Status, formatProgress(p.Watched, p.NumEpisodes))
+		}
+		if p.AnimeID != 0 {
+			anime = strings.TrimSpace(fmt.Sprintf("%s #%d", truncate(p.AnimeTitle, libraryTitleWidth), p.AnimeID))
+		}
+		if p.Confidence > 0 {
+			match = fmt.Sprintf("%.0f%%", p.Confidence*100)
+		}
+
+		onDisk := dashIfEmpty(library.Ranges(p.OnDisk))
+		if p.Specials > 0 {
+			onDisk += fmt.Sprintf(" +%d extra", p.Specials)
+		}
+		if len(p.Unwatched) > 0 {
+			unwatched += len(p.Unwatched)
+			series++
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			truncate(p.Title, libraryTitleWidth), anime, match, onDisk, listed, dashIfEmpty(library.Ranges(p.Unwatched)))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	if unwatched > 0 {
+		fmt.Fprintf(w, "%d episodes of %d series on disk aren't marked watched.\n", unwatched, series)
+	} else {
+		fmt.Fprintln(w, "Every episode on disk is marked watched.")
+	}
+	if len(notOnList) > 0 {
+		fmt.Fprintf(w, "Not on your list: %s\n", strings.Join(notOnList, ", "))
+	}
+	if len(unmatched) > 0 {
+		fmt.Fprintln(w, "Unmatched:")
+		for _, p := range unmatched {
+			if entry := mapping.Series[p.Key]; entry != nil && entry.SuggestedID != 0 {
+				fmt.Fprintf(w, "  %s, best guess %s #%d (%.0f%%)\n", p.Title, entry.AnimeTitle, entry.SuggestedID, entry.Confidence*100)
+			} else {
+				fmt.Fprintf(w, "  %s\n", p.Title)
+			}
+		}
+	}
+	fmt.Fprintf(w, "Fix matches by editing anime_id in %s.\n", mapping.Path())
+	return nil
+}
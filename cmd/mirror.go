@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rinem/ani-track/mirror"
+	"github.com/spf13/cobra"
+)
+
+func MirrorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "Keep a local copy of your lists for fast offline queries",
+	}
+
+	cmd.AddCommand(mirrorPullCmd(), mirrorListCmd())
+
+	return cmd
+}
+
+func mirrorPullCmd() *cobra.Command {
+	var opts mirror.PullOptions
+	var skipManga bool
+
+	cmd := &cobra.Command{
+		Use:   "pull",
+		Short: "Download your anime and manga lists with details",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.AccessToken = accessToken
+			opts.Progress = func(kind string, done, total int) {
+				fmt.Printf("\rFetching %s details %d/%d", kind, done, total)
+				if done == total {
+					fmt.Println()
+				}
+			}
+
+			m, err := openMirror()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			stats, err := m.PullAnime(opts)
+			if saveErr := m.Save(); saveErr != nil {
+				log.Fatal(saveErr)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Anime: %d updated, %d removed, %d unchanged\n", stats.Updated, stats.Removed, stats.Unchanged)
+
+			if skipManga {
+				return
+			}
+
+			stats, err = m.PullManga(opts)
+			if saveErr := m.Save(); saveErr != nil {
+				log.Fatal(saveErr)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Manga: %d updated, %d removed, %d unchanged\n", stats.Updated, stats.Removed, stats.Unchanged)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Username, "user", "u", "@me", "User whose lists to mirror")
+	cmd.Flags().BoolVar(&opts.Full, "full", false, "Refetch details of every entry, not only changed ones")
+	cmd.Flags().BoolVar(&skipManga, "anime-only", false, "Skip the manga list")
+
+	return cmd
+}
+
+func mirrorListCmd() *cobra.Command {
+	var filter mirror.Filter
+	var manga bool
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Query the local mirror",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			m, err := openMirror()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			meta, err := m.Meta()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if meta.PulledAt.IsZero() {
+				log.Fatal("the mirror is empty, run `ani-track mirror pull` first")
+			}
+
+			if manga {
+				entries, err := m.Manga(filter)
+				if err != nil {
+					log.Fatal(err)
+				}
+				sort.Slice(entries, func(i, j int) bool { return entries[i].Node.Title < entries[j].Node.Title })
+				if format == outputJSON {
+					if err := printJSON(os.Stdout, entries); err != nil {
+						log.Fatal(err)
+					}
+					return
+				}
+				for _, e := range entries {
+					fmt.Printf("%-8d %-14s %2d  %s\n", e.Node.ID, e.ListStatus.Status, e.ListStatus.Score, e.Node.Title)
+				}
+				return
+			}
+
+			entries, err := m.Anime(filter)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Node.Title < entries[j].Node.Title })
+			if err := printAnimeEntries(os.Stdout, entries, format); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&manga, "manga", false, "List manga instead of anime")
+	cmd.Flags().StringVar(&filter.Status, "status", "", "Only entries with this list status")
+	cmd.Flags().StringVar(&filter.Genre, "genre", "", "Only entries with this genre")
+	cmd.Flags().StringVar(&filter.Studio, "studio", "", "Only anime by this studio")
+	cmd.Flags().IntVar(&filter.Year, "year", 0, "Only entries that started in this year")
+	cmd.Flags().IntVar(&filter.MinScore, "min-score", 0, "Only entries you scored at least this")
+	addOutputFlag(cmd, &format)
+
+	return cmd
+}
+
+func openMirror() (*mirror.Mirror, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := mirror.Open(filepath.Join(dir, mirror.FileName))
+	if err != nil {
+		return nil, err
+	}
+	return mirror.New(db), nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func addOutputFlag(cmd *cobra.Command, format *string) {
+	cmd.Flags().StringVarP(format, "output", "o", outputTable, "Output format: table or json")
+	cmd.RegisterFlagCompletionFunc("output", completeChoices(outputTable, outputJSON))
+}
+
+func checkOutputFormat(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printAnimeEntries(w io.Writer, entries []api.AnimeListEntry, format string) error {
+	if format == outputJSON {
+		return printJSON(w, entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tSTATUS\tSCORE\tPROGRESS\tTYPE\tYEAR\tSTUDIOS")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Node.ID,
+			e.Node.Title,
+			e.ListStatus.Status,
+			formatScore(e.ListStatus.Score),
+			formatProgress(e.ListStatus.NumEpisodesWatched, e.Node.NumEpisodes),
+			e.Node.MediaType,
+			animeYear(e.Node),
+			studioNames(e.Node),
+		)
+	}
+	return tw.Flush()
+}
+
+func formatScore(score int) string {
+	if score == 0 {
+		return "-"
+	}
+	return strconv.Itoa(score)
+}
+
+func formatProgress(watched, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("%d/?", watched)
+	}
+	return fmt.Sprintf("%d/%d", watched, total)
+}
+
+func animeYear(a api.Anime) string {
+	if a.StartSeason != nil && a.StartSeason.Year > 0 {
+		return strconv.Itoa(a.StartSeason.Year)
+	}
+	if len(a.StartDate) >= 4 {
+		return a.StartDate[:4]
+	}
+	return ""
+}
+
+func studioNames(a api.Anime) string {
+	names := make([]string, len(a.Studios))
+	for i, s := range a.Studios {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// bar renders value as a horizontal bar relative to max.
+func bar(value, max float64, width int) string {
+	if max <= 0 || value <= 0 {
+		return ""
+	}
+	n := int(value / max * float64(width))
+	if n == 0 {
+		return "▏"
+	}
+	return strings.Repeat("█", n)
+}
+
+// truncate shortens s to at most width runes, ending in "…" when cut.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
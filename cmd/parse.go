@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/release"
+	"github.com/spf13/cobra"
+)
+
+// parsedFile is the JSON output of parse.
+type parsedFile struct {
+	File string `json:"file"`
+	release.Release
+}
+
+func ParseCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "parse <filename>...",
+		Short: "Show what is read from the file name of an anime release",
+		Long: `Show what is read from the file name of an anime release: title, season,
+episode or range, special, version, resolution, release group and checksum.
+
+This is the parser used by play, and is meant to debug file names it
+doesn't match.`,
+		Example: `  ani-track parse "[SubsPlease] Sousou no Frieren - 05v2 (1080p) [ABCD1234].mkv"
+  ani-track parse Title.S02E05.1080p.WEB.x264-GROUP.mkv -o json`,
+		Args:        cobra.MinimumNArgs(1),
+		Annotations: map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			parsed := make([]parsedFile, len(args))
+			for i, file := range args {
+				parsed[i] = parsedFile{File: file, Release: release.Parse(file)}
+			}
+
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, parsed); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			for i, p := range parsed {
+				if i > 0 {
+					fmt.Println()
+				}
+				if err := printRelease(os.Stdout, p); err != nil {
+					log.Fatal(err)
+				}
+			}
+		},
+	}
+
+	addOutputFlag(cmd, &format)
+
+	return cmd
+}
+
+func printRelease(w io.Writer, p parsedFile) error {
+	r := p.Release
+	episode := "-"
+	switch {
+	case r.EpisodeEnd > 0:
+		episode = fmt.Sprintf("%d-%d", r.Episode, r.EpisodeEnd)
+	case r.Episode > 0:
+		episode = strconv.Itoa(r.Episode)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "File:\t%s\n", p.File)
+	fmt.Fprintf(tw, "Title:\t%s\n", dashIfEmpty(r.Title))
+	fmt.Fprintf(tw, "Season:\t%s\n", dashIfZero(r.Season))
+	fmt.Fprintf(tw, "Episode:\t%s\n", episode)
+	fmt.Fprintf(tw, "Special:\t%s\n", dashIfEmpty(r.Special))
+	fmt.Fprintf(tw, "Version:\t%s\n", dashIfZero(r.Version))
+	fmt.Fprintf(tw, "Resolution:\t%s\n", dashIfEmpty(r.Resolution))
+	fmt.Fprintf(tw, "Year:\t%s\n", dashIfZero(r.Year))
+	fmt.Fprintf(tw, "Group:\t%s\n", dashIfEmpty(r.Group))
+	fmt.Fprintf(tw, "Checksum:\t%s\n", dashIfEmpty(r.Checksum))
+	return tw.Flush()
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pkg/browser"
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/queue"
+	"github.com/rinem/ani-track/tui"
+)
+
+const (
+	actionDetails = "Show details"
+	actionAdd     = "Add to list"
+	actionStatus  = "Change list status"
+	actionOpen    = "Open on MyAnimeList"
+)
+
+// pickAnime lets the user choose one of results and an action to perform
+// on it. Cancelling either prompt is not an error.
+func pickAnime(results []api.Anime) error {
+	if len(results) == 0 {
+		fmt.Println("No results.")
+		return nil
+	}
+
+	labels := make([]string, len(results))
+	for i, a := range results {
+		labels[i] = animeLabel(a)
+	}
+	i, err := choose("Anime>", labels)
+	if errors.Is(err, tui.ErrCancelled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	anime := results[i]
+
+	listAction := actionAdd
+	if anime.MyListStatus != nil {
+		listAction = actionStatus
+	}
+	actions := []string{actionDetails, listAction, actionOpen}
+	i, err = choose(anime.Title+">", actions)
+	if errors.Is(err, tui.ErrCancelled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch actions[i] {
+	case actionDetails:
+		accessToken, err := readAccessToken()
+		if err != nil {
+			return err
+		}
+		details, err := api.GetAnimeDetails(anime.ID, api.AnimeDetailFields, accessToken)
+		if err != nil {
+			return err
+		}
+		return printAnimeDetails(os.Stdout, details)
+
+	case actionAdd, actionStatus:
+		i, err := choose("Status>", animeListStatuses)
+		if errors.Is(err, tui.ErrCancelled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		status := animeListStatuses[i]
+		return sendOrQueue(os.Stdout, queue.Item{
+			Op:      queue.OpUpdate,
+			AnimeID: anime.ID,
+			Title:   anime.Title,
+			Update:  api.AnimeListUpdate{Status: &status},
+		})
+
+	case actionOpen:
+		url := animeURL(anime.ID)
+		if err := browser.OpenURL(url); err != nil {
+			fmt.Printf("Could not open a browser, visit %s\n", url)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/library"
+	"github.com/rinem/ani-track/player"
+	"github.com/rinem/ani-track/queue"
+	"github.com/rinem/ani-track/release"
+	"github.com/spf13/cobra"
+)
+
+const playerEnv = "ANITRACK_PLAYER"
+
+// playEntry is an anime being played. Without details, when MAL can't be
+// reached, only the episode count is updated.
+type playEntry struct {
+	anime   api.Anime
+	details bool
+}
+
+// playTarget is the list entry a played file counts towards.
+type playTarget struct {
+	entry   *playEntry
+	episode int
+}
+
+func PlayCmd() *cobra.Command {
+	var animeArg, playerCommand string
+	var threshold int
+
+	defaultPlayer := os.Getenv(playerEnv)
+	if defaultPlayer == "" {
+		defaultPlayer = "mpv"
+	}
+
+	cmd := &cobra.Command{
+		Use:   "play <file-or-dir>",
+		Short: "Play episodes in mpv and mark them watched as you go",
+		Long: `Play episodes in mpv and mark them watched as you go.
+
+The anime and episode number are read from each file name, e.g.
+"[Group] Title - 05 (1080p).mkv". Once playback of a file passes the
+threshold, its episode is marked watched like ` + "`ani-track watched`" + ` does.
+Progress never moves backwards, so rewatching an old episode is ignored.
+
+Any player that speaks mpv's JSON IPC protocol and accepts
+--input-ipc-server can be set with --player or ` + playerEnv + `.`,
+		Example: `  ani-track play "[SubsPlease] Sousou no Frieren - 05 (1080p).mkv"
+  ani-track play ~/Anime/Frieren --anime 52991
+  ani-track play . --player "mpv --fs" --threshold 90`,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			if threshold < 1 || threshold > 100 {
+				log.Fatal("threshold must be between 1 and 100")
+			}
+			command, err := player.ParseCommand(playerCommand)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			files, err := player.Files(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			targets, err := playTargets(files, animeArg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(targets) == 0 {
+				log.Fatal("could not match any of the files to an anime, pass it with --anime")
+			}
+
+			p := &player.Player{Command: command, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+			w := &player.Watcher{
+				Threshold: float64(threshold) / 100,
+				OnWatched: func(path string) {
+					if target, ok := targets[path]; ok {
+						markPlayed(target)
+					}
+				},
+			}
+			if err := p.Play(files, w); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&animeArg, "anime", "a", "", "Anime the files belong to, instead of guessing it from the file names")
+	cmd.Flags().StringVar(&playerCommand, "player", defaultPlayer, "Player command, mpv or one compatible with its IPC (env "+playerEnv+")")
+	cmd.Flags().IntVar(&threshold, "threshold", int(player.DefaultThreshold*100), "Percentage of an episode to play before it counts as watched")
+	cmd.RegisterFlagCompletionFunc("anime", completeAnime)
+
+	return cmd
+}
+
+// playTargets matches every file to an anime and episode, resolving each
+// distinct title once. Files that can't be matched are played but not
+// tracked.
+func playTargets(files []string, animeArg string) (map[string]playTarget, error) {
+	accessToken, err := readAccessToken()
+	if err != nil && !api.IsUnavailable(err) {
+		return nil, err
+	}
+
+	var fixed *playEntry
+	if animeArg != "" {
+		anime, err := resolveAnime(animeArg)
+		if err != nil {
+			return nil, err
+		}
+		fixed = playDetails(anime, accessToken)
+	}
+
+	byTitle := map[string]*playEntry{}
+	targets := map[string]playTarget{}
+	for _, file := range files {
+		rel := release.Parse(file)
+		if !rel.HasEpisode() {
+			fmt.Fprintf(os.Stderr, "No episode number in %s, it won't be tracked\n", filepath.Base(file))
+			continue
+		}
+		title := library.Query(rel.Title, rel.Season)
+
+		entry := fixed
+		if entry == nil {
+			var seen bool
+			if entry, seen = byTitle[title]; !seen {
+				resolved, err := resolveTitle(title)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%v, files of %q won't be tracked\n", err, title)
+				} else {
+					entry = playDetails(resolved, accessToken)
+				}
+				byTitle[title] = entry
+			}
+			if entry == nil {
+				continue
+			}
+		}
+
+		targets[file] = playTarget{entry: entry, episode: rel.Episode}
+	}
+	return targets, nil
+}
+
+// playDetails fetches the episode count and list status used to pick the
+// status after an episode, falling back to what the resolver knew when
+// MAL is unreachable.
+func playDetails(anime api.Anime, accessToken string) *playEntry {
+	details, err := api.GetAnimeDetails(anime.ID, "id,title,num_episodes,my_list_status", accessToken)
+	if err != nil {
+		return &playEntry{anime: anime}
+	}
+	return &playEntry{anime: *details, details: true}
+}
+
+// markPlayed marks the episode of target as watched unless the list is
+// already past it.
+func markPlayed(target playTarget) {
+	anime := &target.entry.anime
+	if anime.MyListStatus != nil && anime.MyListStatus.NumEpisodesWatched >= target.episode {
+		fmt.Printf("%s episode %d is already marked watched\n", anime.Title, target.episode)
+		return
+	}
+
+	episode := target.episode
+	item := queue.Item{
+		Op:      queue.OpUpdate,
+		AnimeID: anime.ID,
+		Title:   anime.Title,
+		Update:  api.AnimeListUpdate{NumWatchedEpisodes: &episode},
+	}
+	if target.entry.details {
+		item.Update.Status = watchedStatus(anime, episode)
+	}
+	if err := sendOrQueue(os.Stdout, item); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to mark %s episode %d watched: %v\n", anime.Title, episode, err)
+		return
+	}
+
+	// Later episodes of the same anime build on this one.
+	status := api.AnimeListStatus{NumEpisodesWatched: episode}
+	if anime.MyListStatus != nil {
+		status = *anime.MyListStatus
+		status.NumEpisodesWatched = episode
+	}
+	if item.Update.Status != nil {
+		status.Status = *item.Update.Status
+	}
+	anime.MyListStatus = &status
+}
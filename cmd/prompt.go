@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rinem/ani-track/tui"
+)
+
+// stdin is shared by all line prompts so buffered input is not lost
+// between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+func interactive() bool {
+	return tui.IsTerminal(os.Stdin) && tui.IsTerminal(os.Stdout)
+}
+
+// choose asks the user to pick one of options and returns its index. On a
+// terminal it shows the fuzzy picker, otherwise it falls back to numbered
+// prompts read from stdin so scripts can answer them.
+func choose(prompt string, options []string) (int, error) {
+	if len(options) == 0 {
+		return -1, fmt.Errorf("nothing to choose from")
+	}
+	if interactive() {
+		return tui.Pick(prompt, options)
+	}
+	return chooseNumbered(os.Stdout, prompt, options)
+}
+
+func chooseNumbered(w io.Writer, prompt string, options []string) (int, error) {
+	fmt.Fprintln(w, prompt)
+	for i, option := range options {
+		fmt.Fprintf(w, "%3d) %s\n", i+1, option)
+	}
+
+	for {
+		fmt.Fprintf(w, "Choose 1-%d (empty to cancel): ", len(options))
+		line, err := stdin.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return -1, tui.ErrCancelled
+		}
+
+		n, convErr := strconv.Atoi(line)
+		if convErr == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		if err != nil {
+			return -1, tui.ErrCancelled
+		}
+		fmt.Fprintf(w, "%q is not a valid choice.\n", line)
+	}
+}
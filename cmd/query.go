@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/mirror"
+	"github.com/rinem/ani-track/query"
+	"github.com/spf13/cobra"
+)
+
+const (
+	sourceAuto   = "auto"
+	sourceMirror = "mirror"
+	sourceList   = "list"
+)
+
+func QueryCmd() *cobra.Command {
+	var source, username, format string
+
+	cmd := &cobra.Command{
+		Use:   "query [expression]",
+		Short: "Filter and sort your anime list with a small query language",
+		Long: `Filter and sort your anime list with a small query language.
+
+Expressions compare fields with =, !=, <, <=, >, >=, 'in (a, b)', 'in lo..hi'
+and 'contains', combined with and, or, not and parentheses, and may end with
+'order by <field> [asc|desc], ...' and 'limit <n>'.
+
+Fields: ` + strings.Join(query.AnimeFields, ", ") + `
+
+Example:
+  ani-track query 'status = completed and year in 2015..2019 and studios contains madhouse and score >= 8 order by score desc'`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			q, err := query.Parse(strings.Join(args, " "), query.AnimeFields)
+			if err != nil {
+				log.Fatalf("invalid query: %v", err)
+			}
+
+			entries, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			records := make([]query.Record, len(entries))
+			for i, e := range entries {
+				records[i] = query.AnimeRecord(e)
+			}
+
+			var selected []api.AnimeListEntry
+			for _, i := range q.Select(records) {
+				selected = append(selected, entries[i])
+			}
+
+			if err := printAnimeEntries(os.Stdout, selected, format); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addOutputFlag(cmd, &format)
+
+	return cmd
+}
+
+func addSourceFlags(cmd *cobra.Command, source, username *string) {
+	cmd.Flags().StringVar(source, "source", sourceAuto, "Where to read the list from: mirror, list (the API) or auto")
+	cmd.Flags().StringVarP(username, "user", "u", "@me", "User whose list to read")
+	cmd.RegisterFlagCompletionFunc("source", completeChoices(sourceAuto, sourceMirror, sourceList))
+	cmd.RegisterFlagCompletionFunc("user", completeUsernames)
+}
+
+// loadAnimeEntries returns a user's anime list with details, read from the
+// local mirror when it holds that user's list and from the API otherwise.
+func loadAnimeEntries(source, username string) ([]api.AnimeListEntry, error) {
+	switch source {
+	case sourceAuto, sourceMirror, sourceList:
+	default:
+		return nil, fmt.Errorf("unknown source %q, use mirror, list or auto", source)
+	}
+
+	if source != sourceList {
+		m, err := openMirror()
+		if err != nil {
+			return nil, err
+		}
+		meta, err := m.Meta()
+		if err != nil {
+			return nil, err
+		}
+
+		if !meta.PulledAt.IsZero() && strings.EqualFold(meta.Username, username) {
+			return m.Anime(mirror.Filter{})
+		}
+		if source == sourceMirror {
+			return nil, fmt.Errorf("the mirror has no list of %s, run `ani-track mirror pull --user %s` first", username, username)
+		}
+	}
+
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetFullUserAnimeList(username, api.AnimeListFields, accessToken)
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rinem/ani-track/queue"
+	"github.com/spf13/cobra"
+)
+
+func QueueCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "queue",
+		Short:       "Inspect and replay list changes made while MyAnimeList was unreachable",
+		Annotations: map[string]string{skipQueueFlush: "true"},
+	}
+
+	cmd.AddCommand(queueListCmd(), queueFlushCmd(), queueDropCmd())
+
+	return cmd
+}
+
+func queueListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show queued list changes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := queuePath()
+			if err != nil {
+				log.Fatal(err)
+			}
+			q, err := queue.Load(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if len(q.Items) == 0 {
+				fmt.Println("The queue is empty.")
+				return
+			}
+
+			for _, it := range q.Items {
+				fmt.Printf("#%-4d %s  %s\n", it.ID, it.QueuedAt.Local().Format("2006-01-02 15:04"), it)
+				if it.LastError != "" {
+					fmt.Printf("      last error: %s\n", it.LastError)
+				}
+			}
+		},
+	}
+}
+
+func queueFlushCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Send queued list changes in order",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			q, err := openQueue()
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer q.Close()
+
+			if len(q.Items) == 0 {
+				fmt.Println("The queue is empty.")
+				return
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			result, err := q.Flush(accessToken, force)
+			if err != nil {
+				log.Fatal(err)
+			}
+			printFlushResult(os.Stdout, result)
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Send changes even if the entry changed on MyAnimeList after they were queued")
+
+	return cmd
+}
+
+func queueDropCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "drop [id]",
+		Short: "Discard a queued list change",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.Fatalf("invalid queue id %q", args[0])
+			}
+
+			q, err := openQueue()
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer q.Close()
+
+			if !q.Remove(id) {
+				log.Fatalf("no queued change #%d", id)
+			}
+			if err := q.Save(); err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("Dropped #%d.\n", id)
+		},
+	}
+}
+
+// noFlushCommands are cobra's own commands. Their output is read by shells,
+// and completions run on every TAB, so they never flush the queue.
+var noFlushCommands = map[string]bool{
+	"completion":                    true,
+	"help":                          true,
+	cobra.ShellCompRequestCmd:       true,
+	cobra.ShellCompNoDescRequestCmd: true,
+}
+
+// flushQueueAfterRun replays queued changes once any other command succeeded,
+// MAL is evidently reachable again. Errors are only reported.
+func flushQueueAfterRun(cmd *cobra.Command) {
+	if cacheOptions.Offline {
+		return
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[skipQueueFlush] != "" || (c.HasParent() && noFlushCommands[c.Name()]) {
+			return
+		}
+	}
+
+	q, err := openQueue()
+	if err != nil {
+		return
+	}
+	defer q.Close()
+	if len(q.Items) == 0 {
+		return
+	}
+
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return
+	}
+
+	result, err := q.Flush(accessToken, false)
+	if err != nil {
+		fmt.Printf("Failed to replay queued changes: %v\n", err)
+		return
+	}
+	// Still offline, no need to repeat that after every command.
+	if result.Unavailable != nil && len(result.Sent) == 0 {
+		return
+	}
+	printFlushResult(os.Stdout, result)
+}
+
+const skipQueueFlush = "skip-queue-flush"
+
+func printFlushResult(w io.Writer, result queue.FlushResult) {
+	if len(result.Sent) > 0 {
+		fmt.Fprintf(w, "Sent %d queued change(s):\n", len(result.Sent))
+		for _, it := range result.Sent {
+			fmt.Fprintf(w, "  #%d %s\n", it.ID, it)
+		}
+	}
+
+	for _, c := range result.Conflicts {
+		fmt.Fprintf(w, "Conflict: #%d %s was queued at %s but the entry changed on MyAnimeList at %s.\n",
+			c.Item.ID, c.Item, c.Item.QueuedAt.Local().Format("2006-01-02 15:04"), c.RemoteUpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if len(result.Conflicts) > 0 {
+		fmt.Fprintln(w, "Run `ani-track queue flush --force` to apply them anyway or `ani-track queue drop <id>` to discard them.")
+	}
+
+	for _, it := range result.Failed {
+		fmt.Fprintf(w, "Failed: #%d %s: %s\n", it.ID, it, it.LastError)
+	}
+
+	if result.Unavailable != nil {
+		fmt.Fprintf(w, "MyAnimeList is still unreachable (%v), remaining changes stay queued.\n", result.Unavailable)
+	}
+}
+
+// openQueue opens the queue for changing it. Close it as soon as possible,
+// other commands wait for it meanwhile.
+func openQueue() (*queue.Queue, error) {
+	path, err := queuePath()
+	if err != nil {
+		return nil, err
+	}
+	return queue.Open(path)
+}
+
+func queuePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, queue.FileName), nil
+}
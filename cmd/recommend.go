@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/recommend"
+	"github.com/spf13/cobra"
+)
+
+const candidateFields = "id,title,genres,studios,source,media_type,mean,start_season,num_episodes"
+
+func RecommendCmd() *cobra.Command {
+	var source, username, format string
+	var limit, rankingLimit, seeds int
+	var noSeasonal, noRelated bool
+
+	cmd := &cobra.Command{
+		Use:   "recommend",
+		Short: "Recommend anime based on the scores on your list",
+		Long: `Recommend anime based on the scores on your list.
+
+A taste profile is built from the genres, themes, studios, source material and
+type of the titles you scored, then top ranked, popular, seasonal and related
+anime you have not listed are ranked by similarity to it. Everything is
+fetched through the response cache, so repeated runs work with --offline.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			list, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			model := recommend.NewModel(list)
+			if len(model.Liked()) == 0 {
+				log.Fatal("not enough scored titles to build a profile, score a few more anime first")
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			candidates, err := fetchCandidates(model, accessToken, rankingLimit, seeds, !noSeasonal, !noRelated)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			picks := model.Recommend(candidates, limit)
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, picks); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			printPicks(os.Stdout, picks)
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addOutputFlag(cmd, &format)
+	cmd.Flags().IntVarP(&limit, "limit", "l", 10, "Number of recommendations")
+	cmd.Flags().IntVar(&rankingLimit, "ranking-limit", 100, "Candidates to take from each ranking")
+	cmd.Flags().IntVar(&seeds, "seeds", 10, "Top scored titles whose related anime become candidates")
+	cmd.Flags().BoolVar(&noSeasonal, "no-seasonal", false, "Skip the current season's anime")
+	cmd.Flags().BoolVar(&noRelated, "no-related", false, "Skip anime related to your favourites")
+
+	return cmd
+}
+
+func fetchCandidates(model *recommend.Model, accessToken string, rankingLimit, seeds int, seasonal, related bool) ([]recommend.Candidate, error) {
+	var candidates []recommend.Candidate
+	add := func(nodes []api.Anime, source string) {
+		for _, a := range nodes {
+			candidates = append(candidates, recommend.Candidate{Anime: a, Sources: []string{source}})
+		}
+	}
+
+	for _, rankingType := range []string{"all", "bypopularity"} {
+		nodes, err := api.GetAnimeRanking(rankingType, rankingLimit, 0, candidateFields, accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s ranking: %w", rankingType, err)
+		}
+		add(nodes, "ranking "+rankingType)
+	}
+
+	if seasonal {
+		year, season := api.CurrentSeason(time.Now())
+		nodes, err := api.GetSeasonalAnime(year, season, rankingLimit, candidateFields, accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s %d: %w", season, year, err)
+		}
+		add(nodes, fmt.Sprintf("%s %d", season, year))
+	}
+
+	if related {
+		liked := model.Liked()
+		if len(liked) > seeds {
+			liked = liked[:seeds]
+		}
+		for _, e := range liked {
+			details, err := api.GetAnimeDetails(e.Node.ID, "related_anime", accessToken)
+			if err != nil {
+				return nil, fmt.Errorf("fetching related anime of %s: %w", e.Node.Title, err)
+			}
+			for _, rel := range details.RelatedAnime {
+				if model.OnList(rel.Node.ID) {
+					continue
+				}
+				a, err := api.GetAnimeDetails(rel.Node.ID, candidateFields, accessToken)
+				if err != nil {
+					return nil, fmt.Errorf("fetching %s: %w", rel.Node.Title, err)
+				}
+				add([]api.Anime{*a}, "related to "+e.Node.Title)
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+func printPicks(w io.Writer, picks []recommend.Pick) {
+	if len(picks) == 0 {
+		fmt.Fprintln(w, "No recommendations found.")
+		return
+	}
+
+	for i, p := range picks {
+		mean := ""
+		if p.Anime.Mean > 0 {
+			mean = fmt.Sprintf(", MAL %.2f", p.Anime.Mean)
+		}
+		fmt.Fprintf(w, "%2d. %s [%d] (match %.0f%%%s)\n", i+1, p.Anime.Title, p.Anime.ID, p.Score*100, mean)
+		for _, r := range p.Reasons {
+			fmt.Fprintf(w, "      - %s\n", r)
+		}
+		fmt.Fprintf(w, "      found in: %s\n", strings.Join(p.Sources, ", "))
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/fuzzy"
+	"github.com/rinem/ani-track/mirror"
+	"github.com/rinem/ani-track/tui"
+)
+
+const (
+	// resolveFields are the fields used to rank and label candidates.
+	resolveFields = "id,title,alternative_titles,media_type,start_season,start_date,num_episodes,my_list_status"
+	resolveLimit  = 10
+
+	exactTitleScore = 1000
+	// onListBonus favours titles already on the user's list, which are
+	// the usual targets of update and watched.
+	onListBonus = 50
+)
+
+// animeArgHelp documents resolveAnime in the help of commands using it.
+const animeArgHelp = `The anime can be given as a MyAnimeList ID, an anime URL such as
+https://myanimelist.net/anime/5114/..., or a title. Titles are searched on
+MyAnimeList (or in the local mirror when offline), and you are asked to
+choose when several anime match equally well.`
+
+var animeURLPattern = regexp.MustCompile(`^(?:https?://)?(?:www\.)?myanimelist\.net/anime/(\d+)(?:[/?#].*)?$`)
+
+// resolveAnime turns a command argument into an anime: a MAL ID, a MAL anime
+// URL, or a title. Titles are searched on MAL, or in the local mirror when
+// MAL is unreachable, and the user is asked to choose when several match
+// equally well. Only ID and Title are guaranteed to be set.
+func resolveAnime(arg string) (api.Anime, error) {
+	arg = strings.TrimSpace(arg)
+	if id, err := strconv.Atoi(arg); err == nil && id > 0 {
+		return api.Anime{ID: id}, nil
+	}
+	if m := animeURLPattern.FindStringSubmatch(arg); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return api.Anime{ID: id}, nil
+	}
+	if arg == "" {
+		return api.Anime{}, errors.New("no anime given")
+	}
+	return resolveTitle(arg)
+}
+
+// resolveTitle searches an anime by title only, for titles that may be
+// numbers like "86", e.g. those read from file names.
+func resolveTitle(title string) (api.Anime, error) {
+	candidates, searched, err := titleCandidates(title)
+	if err != nil {
+		return api.Anime{}, err
+	}
+	ranked := rankCandidates(title, candidates, searched)
+	if len(ranked) == 0 {
+		return api.Anime{}, fmt.Errorf("no anime found for %q", title)
+	}
+
+	if len(ranked) == 1 || ranked[0].score >= 2*ranked[1].score && ranked[0].score > 0 {
+		anime := ranked[0].anime
+		fmt.Fprintf(os.Stderr, "Using %s\n", animeLabel(anime))
+		return anime, nil
+	}
+
+	var options []api.Anime
+	for _, c := range ranked {
+		// Only offer the close contenders when there is a clear group at
+		// the top, e.g. the two exact "Hunter x Hunter" matches.
+		if ranked[0].score > 0 && c.score*2 <= ranked[0].score {
+			break
+		}
+		options = append(options, c.anime)
+	}
+	labels := make([]string, len(options))
+	for i, a := range options {
+		labels[i] = animeLabel(a)
+	}
+	i, err := choose(fmt.Sprintf("Which anime did you mean by %q?", title), labels)
+	if errors.Is(err, tui.ErrCancelled) {
+		return api.Anime{}, fmt.Errorf("%q is ambiguous, pass an ID or a more specific title", title)
+	}
+	if err != nil {
+		return api.Anime{}, err
+	}
+	return options[i], nil
+}
+
+// titleCandidates searches MAL for title, falling back to the whole list
+// in the mirror when MAL is unreachable. searched tells which one it was.
+func titleCandidates(title string) (candidates []api.Anime, searched bool, err error) {
+	accessToken, err := readAccessToken()
+	if err == nil {
+		var results []api.Anime
+		results, err = api.SearchAnime(title, resolveLimit, 0, resolveFields, false, accessToken)
+		if err == nil {
+			return results, true, nil
+		}
+	}
+	if !api.IsUnavailable(err) {
+		return nil, false, err
+	}
+
+	m, mirrorErr := openMirror()
+	if mirrorErr != nil {
+		return nil, false, err
+	}
+	entries, mirrorErr := m.Anime(mirror.Filter{})
+	if mirrorErr != nil || len(entries) == 0 {
+		return nil, false, fmt.Errorf("cannot look up %q while MyAnimeList is unreachable, pass the anime ID: %w", title, err)
+	}
+
+	candidates = make([]api.Anime, len(entries))
+	for i, e := range entries {
+		candidates[i] = e.Node
+		status := e.ListStatus
+		candidates[i].MyListStatus = &status
+	}
+	return candidates, false, nil
+}
+
+type candidate struct {
+	anime api.Anime
+	score int
+}
+
+// rankCandidates scores each anime by its best matching title, dropping
+// those where no title matches at all unless keepUnmatched is set. Search
+// results should be kept, MAL may have matched them on something else.
+func rankCandidates(query string, animes []api.Anime, keepUnmatched bool) []candidate {
+	q := normalizeTitle(query)
+	var ranked []candidate
+	for _, a := range animes {
+		best := -1
+		for _, title := range animeTitles(a) {
+			t := normalizeTitle(title)
+			switch {
+			case t == "":
+			case t == q:
+				best = max(best, exactTitleScore)
+			default:
+				best = max(best, fuzzy.Score(q, t))
+			}
+		}
+		if best < 0 {
+			if !keepUnmatched {
+				continue
+			}
+			best = 0
+		}
+		if a.MyListStatus != nil {
+			best += onListBonus
+		}
+		ranked = append(ranked, candidate{a, best})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	return ranked
+}
+
+func animeTitles(a api.Anime) []string {
+	titles := []string{a.Title, a.AlternativeTitles.En, a.AlternativeTitles.Ja}
+	return append(titles, a.AlternativeTitles.Synonyms...)
+}
+
+// normalizeTitle lowercases s and reduces punctuation to single spaces, so
+// "Steins;Gate" matches "steins gate".
+func normalizeTitle(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/auth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheOptions api.CacheOptions
+	profileName  string
+)
+
+// AddGlobalFlags registers the flags shared by every command on rootCmd.
+func AddGlobalFlags(rootCmd *cobra.Command) {
+	profileName = os.Getenv("ANITRACK_PROFILE")
+	if profileName == "" {
+		profileName = auth.DefaultProfile
+	}
+
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&profileName, "profile", profileName, "Stored login to use (env ANITRACK_PROFILE)")
+	flags.BoolVar(&cacheOptions.Disabled, "no-cache", false, "Do not read or write the response cache")
+	flags.BoolVar(&cacheOptions.Refresh, "refresh", false, "Revalidate cached responses with MyAnimeList")
+	flags.BoolVar(&cacheOptions.Offline, "offline", false, "Only serve cached responses, never touch the network")
+
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := applyGlobalFlags(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		flushQueueAfterRun(cmd)
+	}
+}
+
+// applyGlobalFlags selects the profile and configures the response cache
+// from the global flags.
+func applyGlobalFlags() error {
+	if err := auth.SetProfile(profileName); err != nil {
+		return err
+	}
+
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		cacheOptions.Dir = filepath.Join(cacheDir, "ani-track", auth.Profile())
+	}
+	api.SetCacheOptions(cacheOptions)
+	return nil
+}
+
+// dataDir is where local state of the current profile (mirror, queue,
+// mappings) is kept.
+func dataDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "ani-track", auth.Profile()), nil
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/library"
+	"github.com/rinem/ani-track/queue"
+	"github.com/rinem/ani-track/scrobble"
+	"github.com/spf13/cobra"
+)
+
+func ScrobbleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "scrobble",
+		Short:       "Update your list from media server webhooks",
+		Annotations: map[string]string{skipQueueFlush: "true"},
+	}
+
+	cmd.AddCommand(scrobbleServeCmd())
+
+	return cmd
+}
+
+func scrobbleServeCmd() *cobra.Command {
+	var addr, token string
+	var minConfidence float64
+	var window time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Receive Jellyfin, Plex and Kodi webhooks and mark episodes watched",
+		Long: `Receive Jellyfin, Plex and Kodi webhooks and mark episodes watched.
+
+Point the media server's webhook at /jellyfin, /plex or /kodi on this
+server. Watched episodes are matched to an anime by, in order:
+
+  1. the overrides file in the data directory (` + scrobble.OverridesFileName + `), e.g.
+     {"ids": {"anidb:1234": 5114, "tvdb:81797:2": 16498},
+      "titles": {"Shingeki no Kyojin S3": 35760}}
+  2. a MyAnimeList provider ID in the payload
+  3. the matches of ` + "`library scan`" + `
+  4. a title search, accepted above --min-confidence
+
+and marked watched like ` + "`ani-track watched`" + ` does, or queued while MyAnimeList
+is unreachable. Progress never moves backwards, and repeated events for the
+same episode are ignored for --window.`,
+		Example: `  ani-track scrobble serve
+  ani-track scrobble serve --addr 0.0.0.0:8643 --token s3cret   # then use /plex?token=s3cret`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			dir, err := dataDir()
+			if err != nil {
+				log.Fatal(err)
+			}
+			overridesPath := filepath.Join(dir, scrobble.OverridesFileName)
+			if _, err := scrobble.LoadOverrides(overridesPath); err != nil {
+				log.Fatal(err)
+			}
+			mapping, err := openLibraryMapping()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			server := &scrobble.Server{
+				Scrobbler: &listScrobbler{
+					overridesPath: overridesPath,
+					library:       mapping,
+					minConfidence: minConfidence,
+					searched:      map[string]int{},
+				},
+				Token:  token,
+				Window: window,
+				Log:    os.Stdout,
+			}
+
+			fmt.Printf("Listening on http://%s, webhook URLs /jellyfin, /plex and /kodi\n", addr)
+			fmt.Printf("Overrides are read from %s\n", overridesPath)
+			log.Fatal(http.ListenAndServe(addr, server.Handler()))
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8643", "Address to listen on")
+	cmd.Flags().StringVar(&token, "token", "", "Require this value in the token query parameter of webhooks")
+	cmd.Flags().Float64Var(&minConfidence, "min-confidence", 0.6, "Lowest title match confidence, from 0 to 1, to accept")
+	cmd.Flags().DurationVar(&window, "window", scrobble.DefaultWindow, "How long to ignore repeated events for the same episode")
+
+	return cmd
+}
+
+// listScrobbler resolves webhook events to anime and updates the list. The
+// overrides file is read for every event, so edits apply without a restart.
+type listScrobbler struct {
+	overridesPath string
+	library       *library.Mapping
+	minConfidence float64
+	// searched caches title searches by series key, 0 when nothing
+	// matched well enough.
+	searched map[string]int
+}
+
+func (l *listScrobbler) Scrobble(ev scrobble.Event) (scrobble.Result, error) {
+	animeID, err := l.resolve(ev)
+	if err != nil {
+		return scrobble.Result{}, err
+	}
+	if animeID == 0 {
+		return scrobble.Result{
+			Status:  scrobble.StatusUnmatched,
+			Episode: ev.Episode,
+			Message: fmt.Sprintf("no anime found for %s, add it to %s", library.Query(ev.Title, ev.Season), l.overridesPath),
+		}, nil
+	}
+	result := scrobble.Result{AnimeID: animeID, Title: ev.Title, Episode: ev.Episode}
+
+	accessToken, err := readAccessToken()
+	if err != nil && !api.IsUnavailable(err) {
+		return scrobble.Result{}, err
+	}
+	details, err := api.GetAnimeDetails(animeID, "id,title,num_episodes,my_list_status", accessToken)
+	if err != nil && !api.IsUnavailable(err) {
+		return scrobble.Result{}, err
+	}
+
+	episode := ev.Episode
+	item := queue.Item{Op: queue.OpUpdate, AnimeID: animeID, Title: ev.Title, Update: api.AnimeListUpdate{NumWatchedEpisodes: &episode}}
+	if details != nil {
+		result.Title, item.Title = details.Title, details.Title
+		if details.MyListStatus != nil && details.MyListStatus.NumEpisodesWatched >= episode {
+			result.Status, result.Message = scrobble.StatusSkipped, "already marked watched"
+			return result, nil
+		}
+		if details.NumEpisodes > 0 && episode > details.NumEpisodes {
+			result.Status = scrobble.StatusUnmatched
+			result.Message = fmt.Sprintf("%s only has %d episodes, map the season in %s", details.Title, details.NumEpisodes, l.overridesPath)
+			return result, nil
+		}
+		item.Update.Status = watchedStatus(details, episode)
+	}
+
+	var out bytes.Buffer
+	if err := sendOrQueue(&out, item); err != nil {
+		return scrobble.Result{}, err
+	}
+	result.Status = scrobble.StatusUpdated
+	result.Message = strings.Join(strings.Fields(out.String()), " ")
+	return result, nil
+}
+
+// resolve finds the MAL ID of the series of ev, or 0 when nothing matches.
+func (l *listScrobbler) resolve(ev scrobble.Event) (int, error) {
+	overrides, err := scrobble.LoadOverrides(l.overridesPath)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := overrides.Lookup(ev); ok {
+		return id, nil
+	}
+	if id, err := strconv.Atoi(ev.ProviderIDs["mal"]); err == nil && id > 0 {
+		return id, nil
+	}
+
+	key := library.Key(ev.Title, ev.Season)
+	if entry := l.library.Series[key]; entry != nil && entry.AnimeID != 0 && !entry.Ignore {
+		return entry.AnimeID, nil
+	}
+	if id, ok := l.searched[key]; ok {
+		return id, nil
+	}
+	if ev.Title == "" {
+		return 0, nil
+	}
+
+	query := library.Query(ev.Title, ev.Season)
+	candidates, _, err := titleCandidates(query)
+	if err != nil {
+		return 0, err
+	}
+	best, confidence, ok := library.BestMatch(query, candidates)
+	id := 0
+	if ok && confidence >= l.minConfidence {
+		id = best.ID
+	}
+	l.searched[key] = id
+	return id, nil
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+// searchFields are always requested, they feed the table, the filters and
+// animeLabel, which tells results apart in --pick.
+const searchFields = "id,title,alternative_titles,media_type,start_season,start_date,num_episodes,mean,status,genres,my_list_status"
+
+// airingStatuses maps the --status values of search to MAL's.
+var airingStatuses = map[string]string{
+	"airing":   "currently_airing",
+	"finished": "finished_airing",
+	"upcoming": "not_yet_aired",
+}
+
+type searchFilter struct {
+	mediaType string
+	minScore  float64
+	year      int
+	status    string
+	genre     string
+}
+
+func (f searchFilter) match(a api.Anime) bool {
+	if f.mediaType != "" && !strings.EqualFold(a.MediaType, f.mediaType) {
+		return false
+	}
+	if f.minScore > 0 && a.Mean < f.minScore {
+		return false
+	}
+	if f.year > 0 && animeYear(a) != strconv.Itoa(f.year) {
+		return false
+	}
+	if f.status != "" && a.Status != airingStatuses[f.status] {
+		return false
+	}
+	if f.genre != "" {
+		found := false
+		for _, g := range a.Genres {
+			found = found || strings.EqualFold(g.Name, f.genre)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func SearchCmd() *cobra.Command {
+	var limit, offset int
+	var fields, format string
+	var pick, nsfw bool
+	var filter searchFilter
+
+	cmd := &cobra.Command{
+		Use:   "search [query]",
+		Short: "Search for anime on MyAnimeList",
+		Long: `Search for anime on MyAnimeList.
+
+--type, --min-score, --year, --status and --genre filter the results locally,
+after --limit results have been fetched, so raise --limit when filtering
+narrows them down too much. --fields requests extra anime fields, which are
+included in -o json output.
+
+With --pick the results are shown in a fuzzy-filterable list, and the chosen
+anime can be shown in detail, added to your list or opened in the browser.
+When stdin is not a terminal the picker falls back to numbered prompts.`,
+		Example: `  ani-track search fullmetal alchemist
+  ani-track search gundam --limit 50 --type movie --min-score 7.5
+  ani-track search "steins;gate" -o json --fields synopsis,studios`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			query := strings.Join(args, " ")
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+			if filter.status != "" && airingStatuses[filter.status] == "" {
+				log.Fatalf("invalid status %q, use airing, finished or upcoming", filter.status)
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			results, err := api.SearchAnime(query, limit, offset, mergeFields(searchFields, fields), nsfw, accessToken)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var filtered []api.Anime
+			for _, a := range results {
+				if filter.match(a) {
+					filtered = append(filtered, a)
+				}
+			}
+
+			switch {
+			case pick:
+				err = pickAnime(filtered)
+			case format == outputJSON:
+				err = printJSON(os.Stdout, filtered)
+			default:
+				err = printSearchResults(os.Stdout, filtered)
+				if err == nil && len(filtered) < len(results) {
+					fmt.Printf("\n%d of %d results filtered out.\n", len(results)-len(filtered), len(results))
+				}
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addOutputFlag(cmd, &format)
+	cmd.Flags().IntVarP(&limit, "limit", "l", 10, "Number of results to fetch (up to 100)")
+	cmd.Flags().IntVar(&offset, "offset", 0, "Skip this many results, for paging")
+	cmd.Flags().StringVar(&fields, "fields", "", "Extra comma-separated anime fields to request")
+	cmd.Flags().BoolVar(&nsfw, "nsfw", false, "Include adult titles")
+	cmd.Flags().BoolVarP(&pick, "pick", "p", false, "Choose a result interactively and act on it")
+	cmd.Flags().StringVar(&filter.mediaType, "type", "", "Only show this type: tv, movie, ova, ona, special or music")
+	cmd.Flags().Float64Var(&filter.minScore, "min-score", 0, "Only show anime with at least this mean score")
+	cmd.Flags().IntVar(&filter.year, "year", 0, "Only show anime that started this year")
+	cmd.Flags().StringVar(&filter.status, "status", "", "Only show anime that are airing, finished or upcoming")
+	cmd.Flags().StringVar(&filter.genre, "genre", "", "Only show anime with this genre")
+	cmd.RegisterFlagCompletionFunc("type", completeChoices("tv", "movie", "ova", "ona", "special", "music"))
+	cmd.RegisterFlagCompletionFunc("status", completeChoices("airing", "finished", "upcoming"))
+
+	return cmd
+}
+
+func printSearchResults(w io.Writer, results []api.Anime) error {
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(w, "No results.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tTYPE\tYEAR\tEPISODES\tMEAN\tAIRING\tMY STATUS")
+	for _, a := range results {
+		mean := "-"
+		if a.Mean > 0 {
+			mean = strconv.FormatFloat(a.Mean, 'f', 2, 64)
+		}
+		myStatus := "-"
+		if a.MyListStatus != nil {
+			myStatus = a.MyListStatus.Status
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			a.ID,
+			a.Title,
+			dashIfEmpty(a.MediaType),
+			dashIfEmpty(animeYear(a)),
+			dashIfZero(a.NumEpisodes),
+			mean,
+			dashIfEmpty(strings.ReplaceAll(a.Status, "_", " ")),
+			myStatus,
+		)
+	}
+	return tw.Flush()
+}
+
+// mergeFields joins field lists, dropping duplicates. Commas inside braces,
+// as in "studios{name}", do not separate fields.
+func mergeFields(lists ...string) string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, list := range lists {
+		depth, start := 0, 0
+		for i := 0; i <= len(list); i++ {
+			if i < len(list) {
+				switch list[i] {
+				case '{':
+					depth++
+					continue
+				case '}':
+					depth--
+					continue
+				case ',':
+					if depth > 0 {
+						continue
+					}
+				default:
+					continue
+				}
+			}
+			field := strings.TrimSpace(list[start:i])
+			start = i + 1
+			if field != "" && !seen[field] {
+				seen[field] = true
+				merged = append(merged, field)
+			}
+		}
+	}
+	return strings.Join(merged, ",")
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/stats"
+	"github.com/spf13/cobra"
+)
+
+const barWidth = 30
+
+func StatsCmd() *cobra.Command {
+	var source, username, format string
+	var top, months int
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics computed from your anime list",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+
+			entries, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			report := stats.Compute(entries)
+			if format == outputJSON {
+				if err := printJSON(os.Stdout, report); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+
+			printStats(os.Stdout, report, top, months)
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	addOutputFlag(cmd, &format)
+	cmd.Flags().IntVar(&top, "top", 10, "Number of genres and studios to show")
+	cmd.Flags().IntVar(&months, "months", 12, "Number of recent months to show")
+
+	return cmd
+}
+
+func printStats(w io.Writer, r stats.Report, top, months int) {
+	fmt.Fprintf(w, "Entries: %d   Scored: %d   Mean score: %.2f\n", r.Entries, r.Scored, r.MeanScore)
+	fmt.Fprintf(w, "Completion rate: %.0f%%   Drop rate: %.0f%%\n", r.CompletionRate*100, r.DropRate*100)
+	fmt.Fprintf(w, "Hours watched: %.0f", r.TotalHours)
+	if r.UndatedHours > 0 {
+		fmt.Fprintf(w, " (%.0f without dates)", r.UndatedHours)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "\nScore distribution")
+	maxCount := 0
+	for _, c := range r.ScoreHistogram[1:] {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+	for score := 10; score >= 1; score-- {
+		c := r.ScoreHistogram[score]
+		fmt.Fprintf(w, "%3d %-*s %d\n", score, barWidth, bar(float64(c), float64(maxCount), barWidth), c)
+	}
+
+	printPeriods(w, "Hours per year", r.HoursByYear)
+	recent := r.HoursByMonth
+	if months > 0 && len(recent) > months {
+		recent = recent[len(recent)-months:]
+	}
+	printPeriods(w, "Hours per month", recent)
+
+	printRankings(w, "Top genres", r.Genres, top)
+	printRankings(w, "Top studios", r.Studios, top)
+}
+
+func printPeriods(w io.Writer, title string, periods []stats.Period) {
+	if len(periods) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s\n", title)
+	max := 0.0
+	for _, p := range periods {
+		if p.Hours > max {
+			max = p.Hours
+		}
+	}
+	for _, p := range periods {
+		fmt.Fprintf(w, "%-8s %-*s %.1f\n", p.Period, barWidth, bar(p.Hours, max, barWidth), p.Hours)
+	}
+}
+
+func printRankings(w io.Writer, title string, rankings []stats.Ranking, top int) {
+	if len(rankings) == 0 {
+		return
+	}
+	if top > 0 && len(rankings) > top {
+		rankings = rankings[:top]
+	}
+
+	fmt.Fprintf(w, "\n%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tENTRIES\tMEAN\tWEIGHTED\tCOMPLETED\tDROP RATE")
+	for _, rk := range rankings {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%d\t%.0f%%\n", rk.Name, rk.Count, rk.MeanScore, rk.Weighted, rk.Completed, rk.DropRate*100)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/spf13/cobra"
+)
+
+const topFields = "id,title,media_type,start_season,start_date,num_episodes,mean,num_list_users,my_list_status"
+
+func TopCmd() *cobra.Command {
+	var format string
+	var limit, offset int
+
+	cmd := &cobra.Command{
+		Use:       "top [ranking-type]",
+		Short:     "Show the top ranked anime",
+		Long:      "Show the top ranked anime, overall (\"all\", the default) or by ranking type.",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: api.RankingTypes,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkOutputFormat(format); err != nil {
+				log.Fatal(err)
+			}
+			rankingType := "all"
+			if len(args) == 1 {
+				rankingType = args[0]
+			}
+
+			accessToken, err := readAccessToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			ranking, err := api.GetAnimeRanking(rankingType, limit, offset, topFields, accessToken)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if format == outputJSON {
+				err = printJSON(os.Stdout, ranking)
+			} else {
+				err = printRanking(os.Stdout, ranking, offset)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addOutputFlag(cmd, &format)
+	cmd.Flags().IntVarP(&limit, "limit", "l", 20, "Number of anime to show (up to 500)")
+	cmd.Flags().IntVar(&offset, "offset", 0, "Skip this many anime, for paging")
+
+	return cmd
+}
+
+func printRanking(w io.Writer, ranking []api.Anime, offset int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tID\tTITLE\tTYPE\tYEAR\tEPISODES\tMEAN\tMEMBERS\tMY STATUS")
+	for i, a := range ranking {
+		mean := "-"
+		if a.Mean > 0 {
+			mean = strconv.FormatFloat(a.Mean, 'f', 2, 64)
+		}
+		myStatus := "-"
+		if a.MyListStatus != nil {
+			myStatus = a.MyListStatus.Status
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			offset+i+1,
+			a.ID,
+			a.Title,
+			dashIfEmpty(a.MediaType),
+			dashIfEmpty(animeYear(a)),
+			dashIfZero(a.NumEpisodes),
+			mean,
+			dashIfZero(a.NumListUsers),
+			myStatus,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/queue"
+	"github.com/rinem/ani-track/tui"
+	"github.com/spf13/cobra"
+)
+
+// tuiListFields are the fields shown in the TUI's lists, my_list_status
+// lets the Search, Season and Top tabs show list progress before My List
+// has loaded.
+const tuiListFields = "id,title,alternative_titles,media_type,start_season,start_date,mean,num_episodes,status,my_list_status"
+
+const tuiPageSize = 100
+
+func TuiCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tui",
+		Short: "Browse and update your list in a full-screen terminal interface",
+		Long: `Browse and update your list in a full-screen terminal interface.
+
+Tabs show your list, search results, a season's anime and the rankings. Use
+the arrow keys or j/k to move, tab or 1-4 to switch tabs, enter to show
+details, / to search or filter, + and - to change watched episodes, s to
+set the status, r to score and q to quit.
+
+List changes go through the same queue as ` + "`update`" + `, so they are kept
+when MyAnimeList is unreachable.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			if !tui.IsTerminal(os.Stdin) || !tui.IsTerminal(os.Stdout) {
+				log.Fatal("tui needs an interactive terminal")
+			}
+			if _, err := readAccessToken(); err != nil && !api.IsUnavailable(err) {
+				log.Fatal(err)
+			}
+
+			app := tui.NewApp(apiBackend{}, time.Now())
+			if err := tui.Run(app); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+}
+
+// apiBackend serves the TUI from the api package and the response cache.
+type apiBackend struct{}
+
+func (apiBackend) MyList() ([]api.AnimeListEntry, error) {
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetFullUserAnimeList("@me", tuiListFields, accessToken)
+}
+
+func (apiBackend) Search(query string) ([]api.Anime, error) {
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.SearchAnime(query, tuiPageSize, 0, tuiListFields, false, accessToken)
+}
+
+func (apiBackend) Season(year int, season string) ([]api.Anime, error) {
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetSeasonalAnime(year, season, tuiPageSize, tuiListFields, accessToken)
+}
+
+func (apiBackend) Top(rankingType string) ([]api.Anime, error) {
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetAnimeRanking(rankingType, tuiPageSize, 0, tuiListFields, accessToken)
+}
+
+func (apiBackend) Details(id int) (*api.Anime, error) {
+	accessToken, err := readAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetAnimeDetails(id, api.AnimeDetailFields, accessToken)
+}
+
+func (apiBackend) Update(anime api.Anime, update api.AnimeListUpdate) (string, error) {
+	var out bytes.Buffer
+	item := queue.Item{Op: queue.OpUpdate, AnimeID: anime.ID, Title: anime.Title, Update: update}
+	if err := sendOrQueue(&out, item); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out.String()), " "), nil
+}
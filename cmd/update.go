@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/queue"
+	"github.com/spf13/cobra"
+)
+
+var animeListStatuses = []string{"watching", "completed", "on_hold", "dropped", "plan_to_watch"}
+
+func UpdateCmd() *cobra.Command {
+	var status, startDate, finishDate string
+	var score, episodes int
+	var rewatching bool
+
+	cmd := &cobra.Command{
+		Use:               "update [anime]",
+		Short:             "Update an anime on your list",
+		Long:              "Update an anime on your list.\n\n" + animeArgHelp,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeAnime,
+		Annotations:       map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			anime, err := resolveAnime(strings.Join(args, " "))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var update api.AnimeListUpdate
+			if cmd.Flags().Changed("status") {
+				if !validStatus(status) {
+					log.Fatalf("invalid status %q, use one of %v", status, animeListStatuses)
+				}
+				update.Status = &status
+			}
+			if cmd.Flags().Changed("score") {
+				if score < 0 || score > 10 {
+					log.Fatal("score must be between 0 and 10")
+				}
+				update.Score = &score
+			}
+			if cmd.Flags().Changed("episodes") {
+				update.NumWatchedEpisodes = &episodes
+			}
+			if cmd.Flags().Changed("rewatching") {
+				update.IsRewatching = &rewatching
+			}
+			if cmd.Flags().Changed("start") {
+				update.StartDate = &startDate
+			}
+			if cmd.Flags().Changed("finish") {
+				update.FinishDate = &finishDate
+			}
+			if update.String() == "" {
+				log.Fatal("nothing to update, pass at least one flag")
+			}
+
+			item := queue.Item{Op: queue.OpUpdate, AnimeID: anime.ID, Title: anime.Title, Update: update}
+			if err := sendOrQueue(os.Stdout, item); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&status, "status", "s", "", "List status: watching, completed, on_hold, dropped, plan_to_watch")
+	cmd.Flags().IntVar(&score, "score", 0, "Score from 0 to 10")
+	cmd.Flags().IntVarP(&episodes, "episodes", "e", 0, "Number of watched episodes")
+	cmd.Flags().BoolVar(&rewatching, "rewatching", false, "Mark as rewatching")
+	cmd.Flags().StringVar(&startDate, "start", "", "Start date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&finishDate, "finish", "", "Finish date (YYYY-MM-DD)")
+	cmd.RegisterFlagCompletionFunc("status", completeChoices(animeListStatuses...))
+
+	return cmd
+}
+
+func WatchedCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "watched [anime] [episode]",
+		Short: "Mark episodes as watched, the next one when no episode is given",
+		Long: "Mark episodes as watched, the next one when no episode is given.\n\n" + animeArgHelp +
+			"\n\nQuote titles of more than one word, e.g. `ani-track watched \"mob psycho 100\" 3`.",
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeWatched,
+		Annotations:       map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			anime, err := resolveAnime(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			animeID := anime.ID
+
+			accessToken, err := readAccessToken()
+			if err != nil && !api.IsUnavailable(err) {
+				log.Fatal(err)
+			}
+
+			details, err := api.GetAnimeDetails(animeID, "id,title,num_episodes,my_list_status", accessToken)
+			if err != nil && !api.IsUnavailable(err) {
+				log.Fatal(err)
+			}
+
+			var episode int
+			if len(args) == 2 {
+				if episode, err = strconv.Atoi(args[1]); err != nil || episode < 0 {
+					log.Fatalf("invalid episode %q", args[1])
+				}
+			} else {
+				if details == nil {
+					log.Fatal("cannot reach MyAnimeList to read your progress, pass the episode number explicitly")
+				}
+				episode = 1
+				if details.MyListStatus != nil {
+					episode = details.MyListStatus.NumEpisodesWatched + 1
+				}
+			}
+
+			update := api.AnimeListUpdate{NumWatchedEpisodes: &episode}
+			item := queue.Item{Op: queue.OpUpdate, AnimeID: animeID, Title: anime.Title, Update: update}
+			if details != nil {
+				item.Title = details.Title
+				if details.NumEpisodes > 0 && episode > details.NumEpisodes {
+					log.Fatalf("%s only has %d episodes", details.Title, details.NumEpisodes)
+				}
+				item.Update.Status = watchedStatus(details, episode)
+			}
+
+			if err := sendOrQueue(os.Stdout, item); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+}
+
+// watchedStatus moves the entry to completed on the last episode and to
+// watching when it is started, or leaves the status alone.
+func watchedStatus(details *api.Anime, episode int) *string {
+	status := ""
+	switch {
+	case details.NumEpisodes > 0 && episode == details.NumEpisodes:
+		status = "completed"
+	case details.MyListStatus == nil || details.MyListStatus.Status == "plan_to_watch":
+		status = "watching"
+	default:
+		return nil
+	}
+	return &status
+}
+
+func RemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "remove [anime]",
+		Short:             "Remove an anime from your list",
+		Long:              "Remove an anime from your list.\n\n" + animeArgHelp,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeAnime,
+		Annotations:       map[string]string{skipQueueFlush: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			anime, err := resolveAnime(strings.Join(args, " "))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			item := queue.Item{Op: queue.OpDelete, AnimeID: anime.ID, Title: anime.Title}
+			if err := sendOrQueue(os.Stdout, item); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+}
+
+// sendOrQueue sends a list mutation, replaying earlier queued ones first so
+// MAL receives them in order. When MAL cannot be reached, or an earlier change
+// for the same anime is still queued, the mutation is queued for later instead.
+func sendOrQueue(w io.Writer, item queue.Item) error {
+	q, err := openQueue()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	accessToken, err := readAccessToken()
+	if err != nil && !api.IsUnavailable(err) {
+		return err
+	}
+
+	unavailable := err != nil
+	if !unavailable && len(q.Items) > 0 {
+		result, err := q.Flush(accessToken, false)
+		if err != nil {
+			return err
+		}
+		unavailable = result.Unavailable != nil
+		if !unavailable || len(result.Sent) > 0 {
+			printFlushResult(w, result)
+		}
+	}
+
+	// Later changes wait behind a pending one for the same anime, e.g. a
+	// conflict, so they are applied in order.
+	pending := 0
+	for _, it := range q.Items {
+		if it.AnimeID == item.AnimeID {
+			pending = it.ID
+			break
+		}
+	}
+
+	if !unavailable && pending == 0 {
+		err = queue.Send(item, accessToken)
+		if err == nil {
+			fmt.Fprintf(w, "Done: %s\n", item)
+			return nil
+		}
+		if !api.IsUnavailable(err) {
+			return err
+		}
+		unavailable = true
+	}
+
+	queued, err := q.Add(item)
+	if err != nil {
+		return err
+	}
+	if !unavailable {
+		fmt.Fprintf(w, "Queued #%d behind pending #%d for the same anime: %s\n", queued.ID, pending, queued)
+		fmt.Fprintln(w, "Check them with `ani-track queue list`, then send them with `ani-track queue flush --force` or discard them with `ani-track queue drop <id>`.")
+		return nil
+	}
+	fmt.Fprintf(w, "MyAnimeList is unreachable, queued #%d: %s\n", queued.ID, queued)
+	fmt.Fprintln(w, "It will be sent on the next successful call or with `ani-track queue flush`.")
+	return nil
+}
+
+func validStatus(status string) bool {
+	for _, s := range animeListStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rinem/ani-track/wrapped"
+	"github.com/spf13/cobra"
+)
+
+func WrappedCmd() *cobra.Command {
+	var source, username, format, outFile string
+	var year int
+
+	cmd := &cobra.Command{
+		Use:   "wrapped",
+		Short: "Generate a year-in-review report of the anime you completed",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			switch format {
+			case "markdown", "md", "html", outputJSON:
+			default:
+				log.Fatalf("unknown format %q, use markdown, html or json", format)
+			}
+
+			entries, err := loadAnimeEntries(source, username)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			report := wrapped.Build(entries, year)
+			report.Username = username
+
+			// Rendered in memory first, like calendar export, so a failed
+			// render or write never leaves a truncated report behind unnoticed.
+			var buf bytes.Buffer
+			switch format {
+			case "markdown", "md":
+				err = wrapped.RenderMarkdown(&buf, report)
+			case "html":
+				err = wrapped.RenderHTML(&buf, report)
+			default:
+				err = printJSON(&buf, report)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if outFile == "" {
+				_, err = os.Stdout.Write(buf.Bytes())
+			} else {
+				err = os.WriteFile(outFile, buf.Bytes(), 0644)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if outFile != "" {
+				fmt.Printf("Wrote %s\n", outFile)
+			}
+		},
+	}
+
+	addSourceFlags(cmd, &source, &username)
+	cmd.Flags().IntVar(&year, "year", time.Now().Year(), "Year to summarise")
+	cmd.Flags().StringVarP(&format, "format", "f", "markdown", "Output format: markdown, html or json")
+	cmd.RegisterFlagCompletionFunc("format", completeChoices("markdown", "html", "json"))
+	cmd.Flags().StringVar(&outFile, "out", "", "Write the report to this file instead of stdout")
+
+	return cmd
+}
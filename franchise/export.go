@@ -0,0 +1,141 @@
+package franchise
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ListStatus returns the user's status of the node, "" when not listed.
+func (n *Node) ListStatus() string {
+	if n.Anime.MyListStatus == nil {
+		return ""
+	}
+	return n.Anime.MyListStatus.Status
+}
+
+var statusColors = map[string]string{
+	"completed":     "#9be29b",
+	"watching":      "#9bc6f2",
+	"on_hold":       "#f2e29b",
+	"dropped":       "#f29b9b",
+	"plan_to_watch": "#d9d9d9",
+}
+
+func (g *Graph) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Anime.ID < nodes[j].Anime.ID })
+	return nodes
+}
+
+// inverseRelations maps a relation to the one pointing the other way, used
+// to draw prequels as sequels of the earlier title.
+var inverseRelations = map[string]string{
+	"prequel":      "sequel",
+	"parent_story": "side_story",
+	"full_story":   "summary",
+}
+
+// dedupedEdges keeps one edge per pair, MAL lists both directions of most
+// relations (sequel/prequel, parent_story/side_story).
+func (g *Graph) dedupedEdges() []Edge {
+	seen := map[[2]int]bool{}
+	var edges []Edge
+	for _, e := range g.Edges {
+		if inverse, ok := inverseRelations[e.Relation]; ok {
+			e = Edge{From: e.To, To: e.From, Relation: inverse}
+		}
+		if seen[[2]int{e.To, e.From}] || seen[[2]int{e.From, e.To}] {
+			continue
+		}
+		seen[[2]int{e.From, e.To}] = true
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+func nodeLabel(n *Node) string {
+	label := n.Anime.Title
+	var details []string
+	if n.Anime.MediaType != "" {
+		details = append(details, strings.ToUpper(n.Anime.MediaType))
+	}
+	if len(n.Anime.StartDate) >= 4 {
+		details = append(details, n.Anime.StartDate[:4])
+	}
+	if s := n.ListStatus(); s != "" {
+		details = append(details, s)
+	}
+	if len(details) > 0 {
+		label += "\n" + strings.Join(details, " · ")
+	}
+	return label
+}
+
+func WriteDOT(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph franchise {\n  rankdir=LR;\n  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+	for _, n := range g.sortedNodes() {
+		attrs := fmt.Sprintf("label=%s", dotQuote(nodeLabel(n)))
+		if c, ok := statusColors[n.ListStatus()]; ok {
+			attrs += fmt.Sprintf(", fillcolor=%q", c)
+		}
+		if n.Anime.ID == g.Root {
+			attrs += ", penwidth=2"
+		}
+		fmt.Fprintf(&sb, "  a%d [%s];\n", n.Anime.ID, attrs)
+	}
+	for _, e := range g.dedupedEdges() {
+		fmt.Fprintf(&sb, "  a%d -> a%d [label=%s];\n", e.From, e.To, dotQuote(strings.ReplaceAll(e.Relation, "_", " ")))
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func WriteMermaid(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for _, n := range g.sortedNodes() {
+		fmt.Fprintf(&sb, "  a%d[\"%s\"]\n", n.Anime.ID, mermaidEscape(nodeLabel(n)))
+	}
+	for _, e := range g.dedupedEdges() {
+		fmt.Fprintf(&sb, "  a%d -->|%s| a%d\n", e.From, mermaidEscape(strings.ReplaceAll(e.Relation, "_", " ")), e.To)
+	}
+
+	classes := map[string][]string{}
+	for _, n := range g.sortedNodes() {
+		if s := n.ListStatus(); s != "" {
+			classes[s] = append(classes[s], fmt.Sprintf("a%d", n.Anime.ID))
+		}
+	}
+	statuses := make([]string, 0, len(classes))
+	for s := range classes {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	for _, s := range statuses {
+		fmt.Fprintf(&sb, "  classDef %s fill:%s\n", s, statusColors[s])
+		fmt.Fprintf(&sb, "  class %s %s\n", strings.Join(classes[s], ","), s)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func mermaidEscape(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
@@ -0,0 +1,170 @@
+package franchise
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// FetchFields are the anime fields Walk needs from the fetch function.
+const FetchFields = "id,title,start_date,media_type,num_episodes,status,my_list_status,related_anime"
+
+// StoryRelations are followed by default. "character" and "other" links
+// tend to pull in unrelated shows and are only followed when asked.
+var StoryRelations = []string{
+	"sequel", "prequel", "parent_story", "side_story", "full_story",
+	"summary", "alternative_setting", "alternative_version", "spin_off",
+}
+
+type Node struct {
+	Anime api.Anime `json:"anime"`
+	Depth int       `json:"depth"`
+}
+
+type Edge struct {
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	Relation string `json:"relation"`
+}
+
+type Graph struct {
+	Root  int           `json:"root"`
+	Nodes map[int]*Node `json:"nodes"`
+	Edges []Edge        `json:"edges"`
+	// Truncated is set when MaxNodes stopped the walk early.
+	Truncated bool `json:"truncated"`
+}
+
+type WalkOptions struct {
+	// Relations to follow, StoryRelations when empty.
+	Relations []string
+	// MaxNodes bounds the walk, 0 means no limit.
+	MaxNodes int
+}
+
+// Walk visits the relation graph breadth first from root. Every anime is
+// fetched once, so cycles such as sequel/prequel pairs end the walk.
+func Walk(root int, fetch func(id int) (*api.Anime, error), opts WalkOptions) (*Graph, error) {
+	relations := opts.Relations
+	if len(relations) == 0 {
+		relations = StoryRelations
+	}
+	follow := map[string]bool{}
+	for _, r := range relations {
+		follow[r] = true
+	}
+
+	g := &Graph{Root: root, Nodes: map[int]*Node{}}
+	queue := []Node{{Anime: api.Anime{ID: root}}}
+	queued := map[int]bool{root: true}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if opts.MaxNodes > 0 && len(g.Nodes) >= opts.MaxNodes {
+			g.Truncated = true
+			break
+		}
+
+		a, err := fetch(current.Anime.ID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching anime %d: %w", current.Anime.ID, err)
+		}
+		g.Nodes[a.ID] = &Node{Anime: *a, Depth: current.Depth}
+
+		for _, rel := range a.RelatedAnime {
+			if !follow[rel.RelationType] {
+				continue
+			}
+			g.Edges = append(g.Edges, Edge{From: a.ID, To: rel.Node.ID, Relation: rel.RelationType})
+			if !queued[rel.Node.ID] {
+				queued[rel.Node.ID] = true
+				queue = append(queue, Node{Anime: rel.Node, Depth: current.Depth + 1})
+			}
+		}
+	}
+
+	// Drop edges to nodes that were never fetched because of MaxNodes.
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if g.Nodes[e.To] != nil {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+
+	return g, nil
+}
+
+// ReleaseOrder sorts by start date, unknown dates last.
+func (g *Graph) ReleaseOrder() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return releasedBefore(nodes[i], nodes[j]) })
+	return nodes
+}
+
+// ChronologicalOrder follows prequel and sequel links, falling back to
+// release order between unlinked entries and to break cycles.
+func (g *Graph) ChronologicalOrder() []*Node {
+	after := map[int][]int{}
+	indegree := map[int]int{}
+	seen := map[[2]int]bool{}
+	addEdge := func(before, next int) {
+		if before == next || seen[[2]int{before, next}] {
+			return
+		}
+		seen[[2]int{before, next}] = true
+		after[before] = append(after[before], next)
+		indegree[next]++
+	}
+	for _, e := range g.Edges {
+		switch e.Relation {
+		case "sequel":
+			addEdge(e.From, e.To)
+		case "prequel":
+			addEdge(e.To, e.From)
+		}
+	}
+
+	remaining := g.ReleaseOrder()
+	var order []*Node
+	for len(remaining) > 0 {
+		// Earliest released node without unplaced prequels, or the earliest
+		// released one at all when a cycle leaves no such node.
+		pick := 0
+		for i, n := range remaining {
+			if indegree[n.Anime.ID] == 0 {
+				pick = i
+				break
+			}
+		}
+
+		n := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		order = append(order, n)
+		for _, next := range after[n.Anime.ID] {
+			indegree[next]--
+		}
+		indegree[n.Anime.ID] = 0
+	}
+	return order
+}
+
+func releasedBefore(a, b *Node) bool {
+	da, db := a.Anime.StartDate, b.Anime.StartDate
+	if da == "" || db == "" {
+		if da != db {
+			return db == ""
+		}
+		return a.Anime.ID < b.Anime.ID
+	}
+	if da != db {
+		return da < db
+	}
+	return a.Anime.ID < b.Anime.ID
+}
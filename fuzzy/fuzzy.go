@@ -0,0 +1,114 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	matchBonus       = 1
+	consecutiveBonus = 5
+	wordStartBonus   = 8
+	firstCharBonus   = 4
+	// gapPenalty is subtracted for every character skipped between two
+	// matched ones.
+	gapPenalty = 1
+)
+
+const noMatch = -1 << 30
+
+// Score rates how well pattern matches text, ignoring case. Every rune of
+// pattern must occur in text in order, or Score returns -1. Matches at word
+// starts and runs of consecutive runes score higher, the best alignment is
+// found rather than the first one. An empty pattern matches everything
+// with score 0.
+func Score(pattern, text string) int {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0
+	}
+	t := []rune(strings.ToLower(text))
+	if len(p) > len(t) {
+		return -1
+	}
+
+	// prev[j] is the best score with the previous pattern rune matched at
+	// t[j], cur the same for the current rune.
+	prev := make([]int, len(t))
+	cur := make([]int, len(t))
+	for i := range p {
+		// gapped is the best prev[k] for k < j-1, less the gap to j.
+		gapped := noMatch
+		for j := range t {
+			if j >= 2 && prev[j-2] > noMatch {
+				gapped = max(gapped, prev[j-2]) - gapPenalty
+			} else if gapped > noMatch {
+				gapped -= gapPenalty
+			}
+
+			cur[j] = noMatch
+			if t[j] != p[i] {
+				continue
+			}
+			bonus := matchBonus
+			switch {
+			case j == 0:
+				bonus += firstCharBonus + wordStartBonus
+			case !isWordRune(t[j-1]) && isWordRune(t[j]):
+				bonus += wordStartBonus
+			}
+
+			if i == 0 {
+				cur[j] = bonus
+				continue
+			}
+			best := gapped
+			if j >= 1 && prev[j-1] > noMatch {
+				best = max(best, prev[j-1]+consecutiveBonus)
+			}
+			if best > noMatch {
+				cur[j] = best + bonus
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	best := noMatch
+	for _, s := range prev {
+		best = max(best, s)
+	}
+	if best == noMatch {
+		return -1
+	}
+	if best < 0 {
+		// Keep -1 reserved for no match.
+		return 0
+	}
+	return best
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Filter returns the indexes of the items matching pattern, best first.
+// Items scoring the same keep their order.
+func Filter(pattern string, items []string) []int {
+	type match struct {
+		index, score int
+	}
+	var matches []match
+	for i, item := range items {
+		if s := Score(pattern, item); s >= 0 {
+			matches = append(matches, match{i, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
@@ -0,0 +1,181 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rinem/ani-track/release"
+)
+
+// File is a video file of a series.
+type File struct {
+	Path    string          `json:"path"`
+	Release release.Release `json:"release"`
+}
+
+// Series is the files sharing a title and season.
+type Series struct {
+	Key    string `json:"key"`
+	Title  string `json:"title"`
+	Season int    `json:"season,omitempty"`
+	// Dir is the directory holding most of the files.
+	Dir   string `json:"dir"`
+	Files []File `json:"files"`
+}
+
+// Query is the title to search the series by.
+func (s *Series) Query() string {
+	return Query(s.Title, s.Season)
+}
+
+// Query is the title to search a season of a series by. Later seasons are
+// usually listed on MAL as their own entry with the season in the title.
+func Query(title string, season int) string {
+	if season > 1 {
+		return fmt.Sprintf("%s Season %d", title, season)
+	}
+	return title
+}
+
+// Episodes returns the regular episodes on disk in order. A file without
+// an episode number, like a movie, counts as episode 1.
+func (s *Series) Episodes() []int {
+	seen := map[int]bool{}
+	for _, f := range s.Files {
+		r := f.Release
+		switch {
+		case r.Special != "":
+		case r.Episode == 0:
+			seen[1] = true
+		default:
+			for ep := r.Episode; ep <= max(r.Episode, r.EpisodeEnd); ep++ {
+				seen[ep] = true
+			}
+		}
+	}
+
+	episodes := make([]int, 0, len(seen))
+	for ep := range seen {
+		episodes = append(episodes, ep)
+	}
+	sort.Ints(episodes)
+	return episodes
+}
+
+// Specials counts the OVAs, openings and other extras on disk.
+func (s *Series) Specials() int {
+	n := 0
+	for _, f := range s.Files {
+		if f.Release.Special != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Key identifies a series by its normalized title and season, so files of
+// different releases of the same show end up together.
+func Key(title string, season int) string {
+	key := Normalize(title)
+	if season > 1 {
+		key += " s" + strconv.Itoa(season)
+	}
+	return key
+}
+
+// Normalize lowercases s and reduces punctuation to single spaces.
+func Normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Scan walks dir for video files and groups them into series by the title
+// and season in their names. Files whose name has no title, like "05.mkv",
+// take it from their directory.
+func Scan(dir string) ([]*Series, error) {
+	byKey := map[string]*Series{}
+	titles := map[string]map[string]int{}
+	dirs := map[string]map[string]int{}
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !release.IsVideo(path) {
+			return nil
+		}
+
+		r := release.Parse(path)
+		if r.Title == "" {
+			parent := release.Parse(filepath.Base(filepath.Dir(path)))
+			r.Title = parent.Title
+			if r.Season == 0 {
+				r.Season = parent.Season
+			}
+		}
+		if r.Title == "" {
+			return nil
+		}
+
+		key := Key(r.Title, r.Season)
+		s := byKey[key]
+		if s == nil {
+			s = &Series{Key: key, Season: r.Season}
+			byKey[key] = s
+			titles[key] = map[string]int{}
+			dirs[key] = map[string]int{}
+		}
+		s.Files = append(s.Files, File{Path: path, Release: r})
+		titles[key][r.Title]++
+		dirs[key][filepath.Dir(path)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]*Series, 0, len(byKey))
+	for key, s := range byKey {
+		s.Title = mostCommon(titles[key])
+		s.Dir = mostCommon(dirs[key])
+		sort.Slice(s.Files, func(i, j int) bool { return s.Files[i].Path < s.Files[j].Path })
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Key < series[j].Key })
+	return series, nil
+}
+
+// mostCommon returns the most counted string, the first in order on ties.
+func mostCommon(counts map[string]int) string {
+	best, bestCount := "", 0
+	for s, n := range counts {
+		if n > bestCount || n == bestCount && s < best {
+			best, bestCount = s, n
+		}
+	}
+	return best
+}
+
+// Ranges formats sorted episode numbers compactly, e.g. "1-3, 5".
+func Ranges(episodes []int) string {
+	var parts []string
+	for i := 0; i < len(episodes); {
+		j := i
+		for j+1 < len(episodes) && episodes[j+1] == episodes[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(episodes[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", episodes[i], episodes[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,210 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/release"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"Frieren/[SubsPlease] Sousou no Frieren - 01 (1080p).mkv",
+		"Frieren/[SubsPlease] Sousou no Frieren - 02 (1080p).mkv",
+		"Frieren/[SubsPlease] Sousou no Frieren - 02 (1080p).ass",
+		"Downloads/[Erai-raws] Sousou no Frieren - 03 [1080p].mkv",
+		// No title in the file names, it comes from the directory.
+		"Mob Psycho 100 S2/05.mkv",
+		"Vinland Saga/S02E03.mkv",
+		"notes.txt",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	series, err := Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		Key, Title string
+		Season     int
+		Dir        string
+		Files      int
+	}
+	var got []summary
+	for _, s := range series {
+		got = append(got, summary{s.Key, s.Title, s.Season, s.Dir, len(s.Files)})
+	}
+	want := []summary{
+		{"mob psycho 100 s2", "Mob Psycho 100", 2, filepath.Join(root, "Mob Psycho 100 S2"), 1},
+		{"sousou no frieren", "Sousou no Frieren", 0, filepath.Join(root, "Frieren"), 3},
+		{"vinland saga s2", "Vinland Saga", 2, filepath.Join(root, "Vinland Saga"), 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan()\n got %+v\nwant %+v", got, want)
+	}
+
+	if _, err := Scan(filepath.Join(root, "missing")); err == nil {
+		t.Error("Scan of a missing directory succeeded")
+	}
+}
+
+func TestEpisodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases []release.Release
+		want     []int
+	}{
+		{"in order", []release.Release{{Episode: 3}, {Episode: 1}, {Episode: 2}}, []int{1, 2, 3}},
+		{"repeated", []release.Release{{Episode: 2}, {Episode: 2, Version: 2}}, []int{2}},
+		{"range", []release.Release{{Episode: 1, EpisodeEnd: 4}, {Episode: 6}}, []int{1, 2, 3, 4, 6}},
+		{"movie", []release.Release{{Year: 2016}}, []int{1}},
+		{"specials", []release.Release{{Special: "OVA"}, {Episode: 1, Special: "NCOP"}, {Episode: 5}}, []int{5}},
+		{"none", nil, []int{}},
+	}
+
+	for _, tt := range tests {
+		s := &Series{}
+		for _, r := range tt.releases {
+			s.Files = append(s.Files, File{Release: r})
+		}
+		if got := s.Episodes(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Episodes() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		episodes []int
+		want     string
+	}{
+		{nil, ""},
+		{[]int{4}, "4"},
+		{[]int{1, 2, 3}, "1-3"},
+		{[]int{1, 2, 3, 5}, "1-3, 5"},
+		{[]int{1, 3, 5}, "1, 3, 5"},
+		{[]int{2, 3, 7, 8, 9, 12}, "2-3, 7-9, 12"},
+	}
+
+	for _, tt := range tests {
+		if got := Ranges(tt.episodes); got != tt.want {
+			t.Errorf("Ranges(%v) = %q, want %q", tt.episodes, got, tt.want)
+		}
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	onList := &api.AnimeListStatus{Status: "watching"}
+	frieren := api.Anime{ID: 52991, Title: "Sousou no Frieren"}
+	frierenMini := api.Anime{ID: 56885, Title: "Sousou no Frieren: ●● no Mahou"}
+	titan := api.Anime{ID: 16498, Title: "Shingeki no Kyojin"}
+	titan.AlternativeTitles.En = "Attack on Titan"
+	titanOnList := titan
+	titanOnList.ID, titanOnList.MyListStatus = 16499, onList
+
+	tests := []struct {
+		name       string
+		query      string
+		candidates []api.Anime
+		wantID     int
+		wantExact  bool
+	}{
+		{"closest title", "Sousou no Frieren", []api.Anime{frierenMini, frieren}, 52991, true},
+		{"alternative title", "attack on titan", []api.Anime{frieren, titan}, 16498, true},
+		{"list breaks ties", "Attack on Titan", []api.Anime{titan, titanOnList}, 16499, true},
+		{"list first", "Attack on Titan", []api.Anime{titanOnList, titan}, 16499, true},
+		{"search order without list", "Attack on Titan", []api.Anime{titan, func() api.Anime { a := titan; a.ID = 1; return a }()}, 16498, true},
+		{"partial", "Frieren", []api.Anime{titan, frieren}, 52991, false},
+	}
+
+	for _, tt := range tests {
+		got, confidence, ok := BestMatch(tt.query, tt.candidates)
+		if !ok || got.ID != tt.wantID {
+			t.Errorf("%s: BestMatch() = %d, %v, want %d", tt.name, got.ID, ok, tt.wantID)
+		}
+		if exact := confidence == 1; exact != tt.wantExact || confidence <= 0 || confidence > 1 {
+			t.Errorf("%s: confidence %v, want exact %v", tt.name, confidence, tt.wantExact)
+		}
+	}
+
+	if _, confidence, ok := BestMatch("Frieren", nil); ok || confidence != 0 {
+		t.Errorf("BestMatch without candidates = %v, %v, want no match", confidence, ok)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		query, title string
+		want         float64
+	}{
+		{"Steins;Gate", "steins gate", 1},
+		{"night", "nacht", 0.25},
+		{"a", "a", 1},
+		{"a", "b", 0},
+		{"", "anything", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Confidence(tt.query, []string{"", tt.title}); got != tt.want {
+			t.Errorf("Confidence(%q, %q) = %v, want %v", tt.query, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestNewProgress(t *testing.T) {
+	s := &Series{Key: "title", Title: "Title", Dir: "/anime/Title"}
+	for _, r := range []release.Release{{Episode: 1, EpisodeEnd: 4}, {Special: "OVA"}} {
+		s.Files = append(s.Files, File{Release: r})
+	}
+	entry := func(status string, watched int, rewatching bool) map[int]api.AnimeListEntry {
+		return map[int]api.AnimeListEntry{5114: {
+			Node:       api.Anime{ID: 5114, Title: "Listed Title", NumEpisodes: 12},
+			ListStatus: api.AnimeListStatus{Status: status, NumEpisodesWatched: watched, IsRewatching: rewatching},
+		}}
+	}
+	matched := &Entry{AnimeID: 5114, AnimeTitle: "Mapped Title", Confidence: 0.9}
+
+	tests := []struct {
+		name          string
+		entry         *Entry
+		list          map[int]api.AnimeListEntry
+		wantTitle     string
+		wantStatus    string
+		wantUnwatched []int
+	}{
+		{"unmatched", nil, entry("watching", 0, false), "", "", nil},
+		{"ignored", &Entry{Ignore: true}, entry("watching", 0, false), "", "", nil},
+		{"not on the list", matched, nil, "Mapped Title", "", nil},
+		{"watching", matched, entry("watching", 2, false), "Listed Title", "watching", []int{3, 4}},
+		{"caught up", matched, entry("watching", 4, false), "Listed Title", "watching", nil},
+		{"plan to watch", matched, entry("plan_to_watch", 0, false), "Listed Title", "plan_to_watch", []int{1, 2, 3, 4}},
+		{"completed", matched, entry("completed", 0, false), "Listed Title", "completed", nil},
+		{"rewatching", matched, entry("completed", 1, true), "Listed Title", "completed", []int{2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		p := NewProgress(s, tt.entry, tt.list)
+		if p.AnimeTitle != tt.wantTitle || p.Status != tt.wantStatus || !reflect.DeepEqual(p.Unwatched, tt.wantUnwatched) {
+			t.Errorf("%s: NewProgress() = title %q, status %q, unwatched %v, want %q, %q, %v",
+				tt.name, p.AnimeTitle, p.Status, p.Unwatched, tt.wantTitle, tt.wantStatus, tt.wantUnwatched)
+		}
+		if !reflect.DeepEqual(p.OnDisk, []int{1, 2, 3, 4}) || p.Specials != 1 || p.Key != "title" || p.Dir != "/anime/Title" {
+			t.Errorf("%s: NewProgress() = %+v, want the series on disk", tt.name, p)
+		}
+		if wantID := tt.wantTitle != ""; (p.AnimeID == 5114) != wantID {
+			t.Errorf("%s: AnimeID = %d", tt.name, p.AnimeID)
+		}
+	}
+}
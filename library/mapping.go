@@ -0,0 +1,72 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const MappingFileName = "library.json"
+
+// Entry maps a series found on disk to a MAL anime. AnimeID is 0 while the
+// series is unmatched; a match below the confidence threshold is kept as
+// a suggestion instead. Entries with an AnimeID are never matched again,
+// so they can be corrected by hand.
+type Entry struct {
+	Title       string  `json:"title"`
+	Dir         string  `json:"dir,omitempty"`
+	AnimeID     int     `json:"anime_id"`
+	AnimeTitle  string  `json:"anime_title,omitempty"`
+	SuggestedID int     `json:"suggested_id,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`
+	// Ignore skips the series, e.g. for videos that aren't anime.
+	Ignore bool `json:"ignore,omitempty"`
+}
+
+// Mapping is the editable file of series to anime matches, keyed by
+// Series.Key.
+type Mapping struct {
+	path   string
+	Series map[string]*Entry `json:"series"`
+}
+
+func LoadMapping(path string) (*Mapping, error) {
+	m := &Mapping{path: path, Series: map[string]*Entry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Series == nil {
+		m.Series = map[string]*Entry{}
+	}
+	return m, nil
+}
+
+func (m *Mapping) Path() string {
+	return m.path
+}
+
+func (m *Mapping) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
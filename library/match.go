@@ -0,0 +1,66 @@
+package library
+
+import "github.com/rinem/ani-track/api"
+
+// Confidence rates from 0 to 1 how well query matches the closest of
+// titles, by the share of character pairs they have in common.
+func Confidence(query string, titles []string) float64 {
+	q := Normalize(query)
+	best := 0.0
+	for _, title := range titles {
+		best = max(best, similarity(q, Normalize(title)))
+	}
+	return best
+}
+
+// BestMatch picks the candidate whose titles match query best, preferring
+// anime on the list and then the search order on ties.
+func BestMatch(query string, candidates []api.Anime) (api.Anime, float64, bool) {
+	var best api.Anime
+	bestConfidence := -1.0
+	for _, a := range candidates {
+		titles := append([]string{a.Title, a.AlternativeTitles.En, a.AlternativeTitles.Ja}, a.AlternativeTitles.Synonyms...)
+		c := Confidence(query, titles)
+		if c > bestConfidence || c == bestConfidence && a.MyListStatus != nil && best.MyListStatus == nil {
+			best, bestConfidence = a, c
+		}
+	}
+	return best, max(bestConfidence, 0), bestConfidence >= 0
+}
+
+// similarity is the Sørensen–Dice coefficient of the character bigrams of
+// two normalized titles.
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	ba, bb := bigrams(a), bigrams(b)
+	if len(ba) == 0 || len(bb) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, g := range ba {
+		counts[g]++
+	}
+	shared := 0
+	for _, g := range bb {
+		if counts[g] > 0 {
+			counts[g]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ba)+len(bb))
+}
+
+func bigrams(s string) []string {
+	runes := []rune(s)
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
@@ -0,0 +1,55 @@
+package library
+
+import "github.com/rinem/ani-track/api"
+
+// Progress compares the episodes of a series on disk with its list entry.
+type Progress struct {
+	Key        string  `json:"key"`
+	Title      string  `json:"title"`
+	Dir        string  `json:"dir"`
+	AnimeID    int     `json:"anime_id,omitempty"`
+	AnimeTitle string  `json:"anime_title,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	OnDisk     []int   `json:"on_disk"`
+	Specials   int     `json:"specials,omitempty"`
+	// Status is empty when the anime isn't on the list.
+	Status      string `json:"status,omitempty"`
+	Watched     int    `json:"watched"`
+	NumEpisodes int    `json:"num_episodes,omitempty"`
+	// Unwatched are the episodes on disk past the list progress.
+	Unwatched []int `json:"unwatched,omitempty"`
+}
+
+// NewProgress compares s with its list entry in list, keyed by anime ID.
+// An unmatched entry gives a Progress without AnimeID.
+func NewProgress(s *Series, e *Entry, list map[int]api.AnimeListEntry) Progress {
+	p := Progress{
+		Key:      s.Key,
+		Title:    s.Title,
+		Dir:      s.Dir,
+		OnDisk:   s.Episodes(),
+		Specials: s.Specials(),
+	}
+	if e == nil || e.AnimeID == 0 {
+		return p
+	}
+
+	p.AnimeID, p.AnimeTitle, p.Confidence = e.AnimeID, e.AnimeTitle, e.Confidence
+	listEntry, ok := list[e.AnimeID]
+	if !ok {
+		return p
+	}
+
+	if listEntry.Node.Title != "" {
+		p.AnimeTitle = listEntry.Node.Title
+	}
+	p.Status = listEntry.ListStatus.Status
+	p.Watched = listEntry.ListStatus.NumEpisodesWatched
+	p.NumEpisodes = listEntry.Node.NumEpisodes
+	for _, ep := range p.OnDisk {
+		if ep > p.Watched && (p.Status != "completed" || listEntry.ListStatus.IsRewatching) {
+			p.Unwatched = append(p.Unwatched, ep)
+		}
+	}
+	return p
+}
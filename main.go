@@ -15,7 +15,14 @@ const (
 
 func main() {
 	rootCmd := &cobra.Command{Use: "ani-track"}
-	rootCmd.AddCommand(cmd.LoginCmd(), cmd.SearchCmd(), cmd.UserListCmd())
+	rootCmd.AddCommand(cmd.LoginCmd(), cmd.LogoutCmd(), cmd.AuthCmd(), cmd.SearchCmd(), cmd.UserListCmd(),
+		cmd.MirrorCmd(), cmd.UpdateCmd(), cmd.WatchedCmd(), cmd.RemoveCmd(), cmd.QueueCmd(),
+		cmd.QueryCmd(), cmd.StatsCmd(), cmd.WrappedCmd(), cmd.CompareCmd(),
+		cmd.RecommendCmd(), cmd.FranchiseCmd(), cmd.AiringCmd(),
+		cmd.CalendarCmd(), cmd.TuiCmd(),
+		cmd.AnimeCmd(), cmd.TopCmd(), cmd.PlayCmd(),
+		cmd.ParseCmd(), cmd.LibraryCmd(), cmd.ScrobbleCmd())
+	cmd.AddGlobalFlags(rootCmd)
 
 	auth.InitializeOAuthConfig()
 	auth.GetTokenFilePath()
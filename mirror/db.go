@@ -0,0 +1,145 @@
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DB is a small embedded key-value store. Values are JSON documents grouped
+// in buckets, the whole database is kept in memory and written to a single
+// file atomically on Save.
+type DB struct {
+	path    string
+	mu      sync.RWMutex
+	buckets map[string]map[string]json.RawMessage
+	dirty   bool
+}
+
+func Open(path string) (*DB, error) {
+	db := &DB{
+		path:    path,
+		buckets: map[string]map[string]json.RawMessage{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &db.buckets); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) Get(bucket, key string, v interface{}) (bool, error) {
+	db.mu.RLock()
+	raw, ok := db.buckets[bucket][key]
+	db.mu.RUnlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+func (db *DB) Put(bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.buckets[bucket] == nil {
+		db.buckets[bucket] = map[string]json.RawMessage{}
+	}
+	db.buckets[bucket][key] = raw
+	db.dirty = true
+	return nil
+}
+
+func (db *DB) Delete(bucket, key string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.buckets[bucket][key]; ok {
+		delete(db.buckets[bucket], key)
+		db.dirty = true
+	}
+}
+
+// Keys returns the keys of bucket in sorted order.
+func (db *DB) Keys(bucket string) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]string, 0, len(db.buckets[bucket]))
+	for k := range db.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForEach calls fn with every raw value of bucket in key order and stops at
+// the first error.
+func (db *DB) ForEach(bucket string, fn func(key string, raw json.RawMessage) error) error {
+	for _, k := range db.Keys(bucket) {
+		db.mu.RLock()
+		raw, ok := db.buckets[bucket][k]
+		db.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := fn(k, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) Save() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(db.buckets)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(db.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), filepath.Base(db.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), db.path); err != nil {
+		return err
+	}
+
+	db.dirty = false
+	return nil
+}
@@ -0,0 +1,279 @@
+package mirror
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+const (
+	animeBucket = "anime"
+	mangaBucket = "manga"
+	metaBucket  = "meta"
+
+	FileName = "mirror.json"
+)
+
+// Meta describes the last pull.
+type Meta struct {
+	Username string    `json:"username"`
+	PulledAt time.Time `json:"pulled_at"`
+}
+
+// Mirror is a local copy of a user's anime and manga lists where every
+// entry's Node carries the full details.
+type Mirror struct {
+	db *DB
+}
+
+func New(db *DB) *Mirror {
+	return &Mirror{db: db}
+}
+
+func (m *Mirror) Save() error {
+	return m.db.Save()
+}
+
+func (m *Mirror) Meta() (Meta, error) {
+	var meta Meta
+	_, err := m.db.Get(metaBucket, "meta", &meta)
+	return meta, err
+}
+
+type PullOptions struct {
+	Username    string
+	AccessToken string
+	// Full refetches details of every entry instead of only the ones whose
+	// list_status.updated_at changed.
+	Full bool
+	// Progress is called after every fetched detail, may be nil.
+	Progress func(kind string, done, total int)
+}
+
+type PullStats struct {
+	Updated   int
+	Removed   int
+	Unchanged int
+}
+
+// PullAnime syncs the anime list, fetching details only for new entries and
+// entries updated since the last pull.
+func (m *Mirror) PullAnime(opts PullOptions) (PullStats, error) {
+	var stats PullStats
+
+	entries, err := api.GetFullUserAnimeList(opts.Username, "id,title", opts.AccessToken)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := map[string]bool{}
+	var stale []api.AnimeListEntry
+	for _, entry := range entries {
+		key := strconv.Itoa(entry.Node.ID)
+		seen[key] = true
+
+		var stored api.AnimeListEntry
+		found, err := m.db.Get(animeBucket, key, &stored)
+		if err != nil {
+			return stats, err
+		}
+		if found && !opts.Full && stored.ListStatus.UpdatedAt.Equal(entry.ListStatus.UpdatedAt) {
+			stats.Unchanged++
+			continue
+		}
+		stale = append(stale, entry)
+	}
+
+	for i, entry := range stale {
+		details, err := api.GetAnimeDetails(entry.Node.ID, api.AnimeDetailFields, opts.AccessToken)
+		if err != nil {
+			return stats, err
+		}
+		entry.Node = *details
+		if err := m.db.Put(animeBucket, strconv.Itoa(entry.Node.ID), entry); err != nil {
+			return stats, err
+		}
+		stats.Updated++
+		if opts.Progress != nil {
+			opts.Progress("anime", i+1, len(stale))
+		}
+	}
+
+	for _, key := range m.db.Keys(animeBucket) {
+		if !seen[key] {
+			m.db.Delete(animeBucket, key)
+			stats.Removed++
+		}
+	}
+
+	return stats, m.putMeta(opts.Username)
+}
+
+func (m *Mirror) PullManga(opts PullOptions) (PullStats, error) {
+	var stats PullStats
+
+	entries, err := api.GetFullUserMangaList(opts.Username, "id,title", opts.AccessToken)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := map[string]bool{}
+	var stale []api.MangaListEntry
+	for _, entry := range entries {
+		key := strconv.Itoa(entry.Node.ID)
+		seen[key] = true
+
+		var stored api.MangaListEntry
+		found, err := m.db.Get(mangaBucket, key, &stored)
+		if err != nil {
+			return stats, err
+		}
+		if found && !opts.Full && stored.ListStatus.UpdatedAt.Equal(entry.ListStatus.UpdatedAt) {
+			stats.Unchanged++
+			continue
+		}
+		stale = append(stale, entry)
+	}
+
+	for i, entry := range stale {
+		details, err := api.GetMangaDetails(entry.Node.ID, api.MangaDetailFields, opts.AccessToken)
+		if err != nil {
+			return stats, err
+		}
+		entry.Node = *details
+		if err := m.db.Put(mangaBucket, strconv.Itoa(entry.Node.ID), entry); err != nil {
+			return stats, err
+		}
+		stats.Updated++
+		if opts.Progress != nil {
+			opts.Progress("manga", i+1, len(stale))
+		}
+	}
+
+	for _, key := range m.db.Keys(mangaBucket) {
+		if !seen[key] {
+			m.db.Delete(mangaBucket, key)
+			stats.Removed++
+		}
+	}
+
+	return stats, m.putMeta(opts.Username)
+}
+
+func (m *Mirror) putMeta(username string) error {
+	return m.db.Put(metaBucket, "meta", Meta{Username: username, PulledAt: time.Now()})
+}
+
+// Filter narrows down local queries, zero values match everything.
+type Filter struct {
+	Status   string
+	Genre    string
+	Studio   string
+	Year     int
+	MinScore int
+}
+
+func (m *Mirror) Anime(f Filter) ([]api.AnimeListEntry, error) {
+	var result []api.AnimeListEntry
+	err := m.db.ForEach(animeBucket, func(key string, raw json.RawMessage) error {
+		var entry api.AnimeListEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if f.matchAnime(entry) {
+			result = append(result, entry)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (m *Mirror) Manga(f Filter) ([]api.MangaListEntry, error) {
+	var result []api.MangaListEntry
+	err := m.db.ForEach(mangaBucket, func(key string, raw json.RawMessage) error {
+		var entry api.MangaListEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if f.matchManga(entry) {
+			result = append(result, entry)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (f Filter) matchAnime(entry api.AnimeListEntry) bool {
+	if f.Status != "" && entry.ListStatus.Status != f.Status {
+		return false
+	}
+	if f.MinScore > 0 && entry.ListStatus.Score < f.MinScore {
+		return false
+	}
+	if f.Year > 0 && yearOf(entry.Node.StartDate) != f.Year {
+		return false
+	}
+	if f.Genre != "" && !containsName(genreNames(entry.Node.Genres), f.Genre) {
+		return false
+	}
+	if f.Studio != "" {
+		var studios []string
+		for _, s := range entry.Node.Studios {
+			studios = append(studios, s.Name)
+		}
+		if !containsName(studios, f.Studio) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) matchManga(entry api.MangaListEntry) bool {
+	if f.Status != "" && entry.ListStatus.Status != f.Status {
+		return false
+	}
+	if f.MinScore > 0 && entry.ListStatus.Score < f.MinScore {
+		return false
+	}
+	if f.Year > 0 && yearOf(entry.Node.StartDate) != f.Year {
+		return false
+	}
+	if f.Genre != "" && !containsName(genreNames(entry.Node.Genres), f.Genre) {
+		return false
+	}
+	// Manga have no studios, a studio filter never matches.
+	return f.Studio == ""
+}
+
+func genreNames(genres []api.Genre) []string {
+	names := make([]string, len(genres))
+	for i, g := range genres {
+		names[i] = g.Name
+	}
+	return names
+}
+
+func containsName(names []string, want string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// yearOf parses the year of MAL's partial dates ("2015", "2015-04" or
+// "2015-04-05") and returns 0 when unknown.
+func yearOf(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
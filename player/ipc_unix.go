@@ -0,0 +1,18 @@
+//go:build !windows
+
+package player
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+func ipcPath(name string) string {
+	return filepath.Join(os.TempDir(), name+".sock")
+}
+
+func dialIPC(path string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", path)
+}
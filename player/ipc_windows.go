@@ -0,0 +1,19 @@
+//go:build windows
+
+package player
+
+import (
+	"io"
+	"os"
+)
+
+// ipcPath is a named pipe, which is what mpv creates on Windows.
+func ipcPath(name string) string {
+	return `\\.\pipe\` + name
+}
+
+// dialIPC opens the pipe as a file. Its handle is synchronous, which is fine
+// because Watcher doesn't write while it reads.
+func dialIPC(path string) (io.ReadWriteCloser, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
@@ -0,0 +1,257 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/release"
+)
+
+// DefaultThreshold is the fraction of an episode that has to be played for
+// it to count as watched, which skips most ending credits and previews.
+const DefaultThreshold = 0.85
+
+// connectTimeout is how long the player gets to create its IPC socket.
+const connectTimeout = 10 * time.Second
+
+// observed are the mpv properties the watcher follows, by observer ID.
+var observed = []string{"path", "percent-pos"}
+
+type command struct {
+	Command   []any `json:"command"`
+	RequestID int   `json:"request_id,omitempty"`
+}
+
+type message struct {
+	Event string          `json:"event"`
+	Name  string          `json:"name"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+// Watcher follows playback through mpv's JSON IPC protocol and reports each
+// file once when its position passes Threshold.
+//
+// It only writes the observe_property commands up front and reads events
+// afterwards, so it works over connections that can't read and write at the
+// same time, like synchronous named pipes on Windows.
+type Watcher struct {
+	// Threshold is the played fraction, between 0 and 1, after which a
+	// file counts as watched.
+	Threshold float64
+	// OnWatched is called with the path as passed to the player.
+	OnWatched func(path string)
+
+	path    string
+	loaded  bool
+	watched map[string]bool
+}
+
+// Watch subscribes to playback changes on conn and handles events until the
+// player shuts down or closes the connection.
+func (w *Watcher) Watch(conn io.ReadWriter) error {
+	if w.watched == nil {
+		w.watched = map[string]bool{}
+	}
+
+	enc := json.NewEncoder(conn)
+	for i, name := range observed {
+		if err := enc.Encode(command{Command: []any{"observe_property", i + 1, name}, RequestID: i + 1}); err != nil {
+			return fmt.Errorf("mpv ipc: %w", err)
+		}
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var msg message
+		err := dec.Decode(&msg)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("mpv ipc: %w", err)
+		}
+		if msg.Error != "" && msg.Error != "success" {
+			return fmt.Errorf("mpv ipc: %s", msg.Error)
+		}
+		if msg.Event == "shutdown" {
+			return nil
+		}
+		w.handle(msg)
+	}
+}
+
+func (w *Watcher) handle(msg message) {
+	switch msg.Event {
+	case "start-file", "end-file":
+		w.loaded = false
+	case "file-loaded":
+		w.loaded = true
+	case "property-change":
+		switch msg.Name {
+		case "path":
+			w.path = ""
+			json.Unmarshal(msg.Data, &w.path)
+		case "percent-pos":
+			var percent float64
+			if json.Unmarshal(msg.Data, &percent) != nil || !w.loaded || w.path == "" {
+				return
+			}
+			if percent/100 >= w.Threshold && !w.watched[w.path] {
+				w.watched[w.path] = true
+				if w.OnWatched != nil {
+					w.OnWatched(w.path)
+				}
+			}
+		}
+	}
+}
+
+// Player is a media player controlled through mpv's JSON IPC, mpv itself or
+// a frontend that passes --input-ipc-server on to it.
+type Player struct {
+	// Command is the executable and any extra arguments.
+	Command []string
+	// Stdin, Stdout and Stderr are handed to the player, usually the
+	// terminal so its keyboard controls keep working.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+}
+
+// Play starts the player with files as its playlist and watches playback
+// until the player exits.
+func (p *Player) Play(files []string, w *Watcher) error {
+	if len(p.Command) == 0 {
+		return errors.New("no player command configured")
+	}
+
+	socket := socketPath()
+	args := append(append([]string{}, p.Command[1:]...), "--input-ipc-server="+socket, "--")
+	cmd := exec.Command(p.Command[0], append(args, files...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = p.Stdin, p.Stdout, p.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	conn, err := connect(socket, exited)
+	if err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	defer conn.Close()
+
+	if err := w.Watch(conn); err != nil {
+		return err
+	}
+	return <-exited
+}
+
+// connect dials the IPC socket, retrying while the player is starting up.
+func connect(socket string, exited chan error) (io.ReadWriteCloser, error) {
+	deadline := time.Now().Add(connectTimeout)
+	for {
+		conn, err := dialIPC(socket)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("player did not open its IPC socket %s: %w", socket, err)
+		}
+
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return nil, fmt.Errorf("player stopped before opening its IPC socket: %w", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// ParseCommand splits a player command line on spaces, keeping quoted
+// arguments together.
+func ParseCommand(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in player command %q", s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// Files expands path into the video files to play: the file itself, or the
+// video files in a directory and its subdirectories in name order.
+func Files(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{abs}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(abs, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && release.IsVideo(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no video files in %s", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func socketPath() string {
+	return ipcPath("ani-track-mpv-" + strconv.Itoa(os.Getpid()))
+}
@@ -0,0 +1,200 @@
+package player
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeMpv plays the mpv side of an IPC connection: it reads the observe
+// commands of the watcher, then sends events, one JSON object per line.
+// It closes the connection afterwards unless keepOpen is set.
+func fakeMpv(t *testing.T, conn net.Conn, events []string, keepOpen bool) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		dec := json.NewDecoder(conn)
+		for i, name := range observed {
+			var cmd command
+			if err := dec.Decode(&cmd); err != nil {
+				done <- fmt.Errorf("reading command %d: %w", i+1, err)
+				return
+			}
+			want := []any{"observe_property", float64(i + 1), name}
+			if !reflect.DeepEqual(cmd.Command, want) {
+				done <- fmt.Errorf("command %d = %v, want %v", i+1, cmd.Command, want)
+				return
+			}
+		}
+		// Like a socket buffer, take whatever else the watcher writes.
+		go io.Copy(io.Discard, conn)
+
+		for _, ev := range events {
+			if _, err := conn.Write([]byte(ev + "\n")); err != nil {
+				done <- err
+				return
+			}
+		}
+		if !keepOpen {
+			conn.Close()
+		}
+		done <- nil
+	}()
+	return done
+}
+
+func path(p string) string {
+	return fmt.Sprintf(`{"event":"property-change","id":1,"name":"path","data":%q}`, p)
+}
+
+func percent(p float64) string {
+	return fmt.Sprintf(`{"event":"property-change","id":2,"name":"percent-pos","data":%v}`, p)
+}
+
+func watch(t *testing.T, threshold float64, events []string, keepOpen bool) ([]string, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var watched []string
+	w := &Watcher{Threshold: threshold, OnWatched: func(p string) { watched = append(watched, p) }}
+	done := fakeMpv(t, server, events, keepOpen)
+	err := w.Watch(client)
+	if serverErr := <-done; serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	return watched, err
+}
+
+func TestWatch(t *testing.T) {
+	events := []string{
+		`{"request_id":1,"error":"success"}`,
+		`{"request_id":2,"error":"success"}`,
+		`{"event":"property-change","id":1,"name":"path","data":null}`,
+		`{"event":"property-change","id":2,"name":"percent-pos","data":null}`,
+		`{"event":"start-file","playlist_entry_id":1}`,
+		path("/anime/a - 01.mkv"),
+		// The position of the previous file until the new one is loaded.
+		percent(97),
+		`{"event":"file-loaded"}`,
+		percent(0),
+		percent(50),
+		percent(84.9),
+		percent(85),
+		percent(90),
+		`{"event":"seek"}`,
+		percent(10),
+		percent(99),
+		`{"event":"end-file","reason":"eof"}`,
+		`{"event":"start-file","playlist_entry_id":2}`,
+		path("/anime/a - 02.mkv"),
+		percent(100),
+		`{"event":"file-loaded"}`,
+		percent(30),
+		`{"event":"end-file","reason":"stop"}`,
+		// Back to the first file, which is reported only once.
+		`{"event":"start-file","playlist_entry_id":1}`,
+		path("/anime/a - 01.mkv"),
+		`{"event":"file-loaded"}`,
+		percent(95),
+	}
+
+	watched, err := watch(t, DefaultThreshold, events, false)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if want := []string{"/anime/a - 01.mkv"}; !reflect.DeepEqual(watched, want) {
+		t.Errorf("watched %q, want %q", watched, want)
+	}
+}
+
+func TestWatchThreshold(t *testing.T) {
+	events := []string{
+		path("/anime/b.mkv"),
+		`{"event":"file-loaded"}`,
+		percent(49),
+		percent(50),
+	}
+
+	for _, tt := range []struct {
+		threshold float64
+		want      int
+	}{{0.5, 1}, {0.51, 0}, {0.01, 1}} {
+		watched, err := watch(t, tt.threshold, events, false)
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		if len(watched) != tt.want {
+			t.Errorf("threshold %v: watched %q, want %d files", tt.threshold, watched, tt.want)
+		}
+	}
+}
+
+func TestWatchShutdown(t *testing.T) {
+	// Watch returns on shutdown even though the connection stays open.
+	events := []string{
+		path("/anime/c.mkv"),
+		`{"event":"file-loaded"}`,
+		percent(90),
+		`{"event":"shutdown"}`,
+	}
+
+	watched, err := watch(t, DefaultThreshold, events, true)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if len(watched) != 1 {
+		t.Errorf("watched %q, want c.mkv", watched)
+	}
+}
+
+func TestWatchEOF(t *testing.T) {
+	// The player quitting mid-message is no error either.
+	events := []string{path("/anime/d.mkv"), `{"event":`}
+
+	if _, err := watch(t, DefaultThreshold, events, false); err != nil {
+		t.Errorf("Watch: %v, want nil at EOF", err)
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	for _, events := range [][]string{
+		{`{"request_id":1,"error":"property not found"}`},
+		{`not json`},
+	} {
+		if _, err := watch(t, DefaultThreshold, events, true); err == nil || !strings.HasPrefix(err.Error(), "mpv ipc: ") {
+			t.Errorf("Watch after %q returned %v, want an mpv ipc error", events, err)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{"mpv", []string{"mpv"}, false},
+		{"  mpv   --fs\t--volume=50 ", []string{"mpv", "--fs", "--volume=50"}, false},
+		{`"/Applications/IINA.app/Contents/MacOS/iina-cli" --mpv-fs`, []string{"/Applications/IINA.app/Contents/MacOS/iina-cli", "--mpv-fs"}, false},
+		{`mpv --title='my player' --profile="low latency"`, []string{"mpv", "--title=my player", "--profile=low latency"}, false},
+		{`mpv "it's"`, []string{"mpv", "it's"}, false},
+		{`mpv ''`, []string{"mpv", ""}, false},
+		{"", nil, false},
+		{`mpv "--fs`, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCommand(tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCommand(%q) error = %v, want error %v", tt.command, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
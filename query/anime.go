@@ -0,0 +1,74 @@
+package query
+
+import (
+	"github.com/rinem/ani-track/api"
+)
+
+// AnimeFields are the fields available on records built by AnimeRecord.
+var AnimeFields = []string{
+	"id", "title", "english", "status", "score", "watched", "episodes", "rewatching",
+	"started", "finished", "updated", "mean", "rank", "popularity", "members", "type",
+	"airing", "source", "rating", "year", "season", "start_date", "end_date", "genres",
+	"studios", "duration",
+}
+
+// AnimeRecord flattens a list entry with details into a Record. Dates stay
+// strings since MAL's YYYY-MM-DD format sorts correctly.
+func AnimeRecord(e api.AnimeListEntry) Record {
+	n := e.Node
+	r := Record{
+		"id":         float64(n.ID),
+		"title":      n.Title,
+		"english":    n.AlternativeTitles.En,
+		"status":     e.ListStatus.Status,
+		"score":      float64(e.ListStatus.Score),
+		"watched":    float64(e.ListStatus.NumEpisodesWatched),
+		"episodes":   float64(n.NumEpisodes),
+		"rewatching": e.ListStatus.IsRewatching,
+		"started":    e.ListStatus.StartDate,
+		"finished":   e.ListStatus.FinishDate,
+		"mean":       n.Mean,
+		"rank":       float64(n.Rank),
+		"popularity": float64(n.Popularity),
+		"members":    float64(n.NumListUsers),
+		"type":       n.MediaType,
+		"airing":     n.Status,
+		"source":     n.Source,
+		"rating":     n.Rating,
+		"start_date": n.StartDate,
+		"end_date":   n.EndDate,
+		"duration":   float64(n.AverageEpisodeDuration) / 60,
+	}
+
+	if !e.ListStatus.UpdatedAt.IsZero() {
+		r["updated"] = e.ListStatus.UpdatedAt.Format("2006-01-02")
+	}
+	if n.StartSeason != nil {
+		r["year"] = float64(n.StartSeason.Year)
+		r["season"] = n.StartSeason.Season
+	} else if len(n.StartDate) >= 4 {
+		r["year"] = n.StartDate[:4]
+	}
+
+	var genres []string
+	for _, g := range n.Genres {
+		genres = append(genres, g.Name)
+	}
+	r["genres"] = genres
+
+	var studios []string
+	for _, s := range n.Studios {
+		studios = append(studios, s.Name)
+	}
+	r["studios"] = studios
+
+	// Unknown numbers and unscored entries are missing rather than zero so
+	// they neither match comparisons nor sort first.
+	for _, f := range []string{"score", "episodes", "mean", "rank", "popularity", "members", "duration"} {
+		if r[f] == float64(0) {
+			delete(r, f)
+		}
+	}
+
+	return r
+}
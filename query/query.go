@@ -0,0 +1,506 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record maps field names to values, which are float64, string, bool or
+// []string. Missing fields never match a comparison.
+type Record map[string]interface{}
+
+// Query is a parsed filter expression with optional ordering and limit:
+//
+//	status = completed and year in 2015..2019 and studios contains madhouse
+//	and score >= 8 order by score desc, title limit 10
+type Query struct {
+	Where   Expr
+	OrderBy []OrderItem
+	Limit   int
+}
+
+type OrderItem struct {
+	Field string
+	Desc  bool
+}
+
+type Expr interface {
+	Eval(r Record) bool
+}
+
+type andExpr struct{ left, right Expr }
+type orExpr struct{ left, right Expr }
+type notExpr struct{ expr Expr }
+
+func (e andExpr) Eval(r Record) bool { return e.left.Eval(r) && e.right.Eval(r) }
+func (e orExpr) Eval(r Record) bool  { return e.left.Eval(r) || e.right.Eval(r) }
+func (e notExpr) Eval(r Record) bool { return !e.expr.Eval(r) }
+
+type compareExpr struct {
+	field string
+	op    string
+	value interface{}
+}
+
+func (e compareExpr) Eval(r Record) bool {
+	v, ok := r[e.field]
+	if !ok || v == nil {
+		return false
+	}
+
+	if list, ok := v.([]string); ok {
+		switch e.op {
+		case "=":
+			for _, item := range list {
+				if compareValues(item, e.value) == 0 {
+					return true
+				}
+			}
+			return false
+		case "!=":
+			for _, item := range list {
+				if compareValues(item, e.value) == 0 {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+
+	c := compareValues(v, e.value)
+	switch e.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c == -1
+	case "<=":
+		return c == -1 || c == 0
+	case ">":
+		return c == 1
+	case ">=":
+		return c == 1 || c == 0
+	}
+	return false
+}
+
+type inExpr struct {
+	field  string
+	values []interface{}
+	// lo and hi are set for ranges like 2015..2019.
+	lo, hi *float64
+}
+
+func (e inExpr) Eval(r Record) bool {
+	v, ok := r[e.field]
+	if !ok || v == nil {
+		return false
+	}
+
+	items := []interface{}{v}
+	if list, ok := v.([]string); ok {
+		items = items[:0]
+		for _, s := range list {
+			items = append(items, s)
+		}
+	}
+
+	for _, item := range items {
+		if e.lo != nil {
+			if n, ok := toNumber(item); ok && n >= *e.lo && n <= *e.hi {
+				return true
+			}
+			continue
+		}
+		for _, want := range e.values {
+			if compareValues(item, want) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type containsExpr struct {
+	field string
+	value string
+}
+
+func (e containsExpr) Eval(r Record) bool {
+	switch v := r[e.field].(type) {
+	case []string:
+		for _, item := range v {
+			if strings.EqualFold(item, e.value) {
+				return true
+			}
+		}
+	case string:
+		return strings.Contains(strings.ToLower(v), strings.ToLower(e.value))
+	}
+	return false
+}
+
+// compareValues returns -1, 0 or 1, or 2 when a and b cannot be compared.
+// Numbers compare numerically, everything else as case-insensitive strings.
+func compareValues(a, b interface{}) int {
+	if an, ok := toNumber(a); ok {
+		if bn, ok := toNumber(b); ok {
+			switch {
+			case an < bn:
+				return -1
+			case an > bn:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	as, aok := toString(a)
+	bs, bok := toString(b)
+	if !aok || !bok {
+		return 2
+	}
+	return strings.Compare(strings.ToLower(as), strings.ToLower(bs))
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(s), true
+	}
+	return "", false
+}
+
+// Select returns the indexes of the records matching q, ordered and limited.
+func (q *Query) Select(records []Record) []int {
+	var idx []int
+	for i, r := range records {
+		if q.Where == nil || q.Where.Eval(r) {
+			idx = append(idx, i)
+		}
+	}
+
+	if len(q.OrderBy) > 0 {
+		sort.SliceStable(idx, func(a, b int) bool {
+			ra, rb := records[idx[a]], records[idx[b]]
+			for _, o := range q.OrderBy {
+				va, vb := sortKey(ra[o.Field]), sortKey(rb[o.Field])
+				// Missing values go last in either direction.
+				if va == nil || vb == nil {
+					if (va == nil) != (vb == nil) {
+						return vb == nil
+					}
+					continue
+				}
+				c := compareValues(va, vb)
+				if c == 0 || c == 2 {
+					continue
+				}
+				if o.Desc {
+					return c == 1
+				}
+				return c == -1
+			}
+			return false
+		})
+	}
+
+	if q.Limit > 0 && len(idx) > q.Limit {
+		idx = idx[:q.Limit]
+	}
+	return idx
+}
+
+func sortKey(v interface{}) interface{} {
+	switch s := v.(type) {
+	case []string:
+		if len(s) == 0 {
+			return nil
+		}
+		return s[0]
+	case string:
+		if s == "" {
+			return nil
+		}
+	}
+	return v
+}
+
+// Parse parses input, rejecting fields that are not in fields.
+func Parse(input string, fields []string) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, fields: map[string]bool{}}
+	for _, f := range fields {
+		p.fields[f] = true
+	}
+
+	return p.parseQuery()
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	q := &Query{}
+
+	if !p.peek().isKeyword("order") && !p.peek().isKeyword("limit") && p.peek().kind != tokEOF {
+		where, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		q.Where = where
+	}
+
+	if p.peek().isKeyword("order") {
+		p.next()
+		if t := p.next(); !t.isKeyword("by") {
+			return nil, p.errorf(t, "expected 'by' after 'order', got %s", t)
+		}
+		for {
+			field, err := p.parseField()
+			if err != nil {
+				return nil, err
+			}
+			item := OrderItem{Field: field}
+			if p.peek().isKeyword("desc") {
+				p.next()
+				item.Desc = true
+			} else if p.peek().isKeyword("asc") {
+				p.next()
+			}
+			q.OrderBy = append(q.OrderBy, item)
+
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.peek().isKeyword("limit") {
+		p.next()
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, p.errorf(t, "expected a number after 'limit', got %s", t)
+		}
+		q.Limit = n
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.peek().isKeyword("not") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "expected ')', got %s", t)
+		}
+		return expr, nil
+	}
+
+	field, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == tokOp:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return compareExpr{field: field, op: t.text, value: value}, nil
+
+	case t.isKeyword("contains"):
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		s, _ := toString(value)
+		return containsExpr{field: field, value: s}, nil
+
+	case t.isKeyword("not") && p.peek().isKeyword("in"):
+		p.next()
+		in, err := p.parseIn(field)
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{in}, nil
+
+	case t.isKeyword("in"):
+		return p.parseIn(field)
+	}
+
+	return nil, p.errorf(t, "expected a comparison, 'in' or 'contains' after %q, got %s", field, t)
+}
+
+// parseIn parses either a list "(a, b, c)" or a numeric range "lo..hi".
+func (p *parser) parseIn(field string) (Expr, error) {
+	if p.peek().kind != tokLParen {
+		lo, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRange {
+			return nil, p.errorf(t, "expected '..' in range, got %s", t)
+		}
+		hi, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return inExpr{field: field, lo: &lo, hi: &hi}, nil
+	}
+
+	p.next()
+	e := inExpr{field: field}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		e.values = append(e.values, value)
+
+		t := p.next()
+		if t.kind == tokRParen {
+			return e, nil
+		}
+		if t.kind != tokComma {
+			return nil, p.errorf(t, "expected ',' or ')', got %s", t)
+		}
+	}
+}
+
+func (p *parser) parseField() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf(t, "expected a field name, got %s", t)
+	}
+
+	field := strings.ToLower(t.text)
+	if !p.fields[field] {
+		return "", p.errorf(t, "unknown field %q", t.text)
+	}
+	return field, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t)
+		}
+		return n, nil
+	case tokString, tokIdent:
+		// Bare words such as completed or madhouse are strings too.
+		return t.text, nil
+	}
+	return nil, p.errorf(t, "expected a value, got %s", t)
+}
+
+func (p *parser) parseNumber() (float64, error) {
+	t := p.next()
+	n, err := strconv.ParseFloat(t.text, 64)
+	if t.kind != tokNumber || err != nil {
+		return 0, p.errorf(t, "expected a number, got %s", t)
+	}
+	return n, nil
+}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rinem/ani-track/api"
+)
+
+var testFields = []string{"title", "status", "score", "year", "episodes", "studios", "genres", "start_date"}
+
+var testRecords = []Record{
+	{"title": "Fullmetal Alchemist: Brotherhood", "status": "completed", "score": 10.0, "year": 2009.0,
+		"episodes": 64.0, "studios": []string{"Bones"}, "genres": []string{"Action", "Adventure"}, "start_date": "2009-04-05"},
+	{"title": "One Punch Man", "status": "completed", "score": 8.0, "year": 2015.0,
+		"episodes": 12.0, "studios": []string{"Madhouse"}, "genres": []string{"Action", "Comedy"}, "start_date": "2015-10-05"},
+	{"title": "Death Parade", "status": "dropped", "score": 6.0, "year": 2015.0,
+		"episodes": 12.0, "studios": []string{"Madhouse"}, "genres": []string{"Drama"}, "start_date": "2015-01-10"},
+	// An unscored entry as the query command builds it.
+	AnimeRecord(api.AnimeListEntry{
+		Node: api.Anime{
+			ID: 52991, Title: "Frieren", NumEpisodes: 28, StartDate: "2023-09-29",
+			StartSeason: &api.Season{Year: 2023, Season: "fall"},
+			Studios:     []api.Studio{{Name: "Madhouse"}},
+			Genres:      []api.Genre{{Name: "Adventure"}, {Name: "Drama"}},
+		},
+		ListStatus: api.AnimeListStatus{Status: "watching", NumEpisodesWatched: 10},
+	}),
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		// and binds tighter than or, not tighter than and.
+		{"status = dropped or status = completed and score >= 9", []int{0, 2}},
+		{"(status = dropped or status = completed) and score >= 9", []int{0}},
+		{"not status = completed and year = 2015", []int{2}},
+		{"not (status = completed and year = 2015)", []int{0, 2, 3}},
+		{"not not status = watching", []int{3}},
+
+		// Numbers compare numerically, strings case-insensitively.
+		{"episodes > 12", []int{0, 3}},
+		{"episodes >= 12 and episodes < 28", []int{1, 2}},
+		{"score != 8", []int{0, 2}},
+		{"score = 8.0", []int{1}},
+		{"year ≥ 2015 and year ≤ 2015", []int{1, 2}},
+		{"status == COMPLETED", []int{0, 1}},
+		{`title = "death parade"`, []int{2}},
+		{"title < 'F'", []int{2}},
+		{"start_date >= 2015-01-01 and start_date < 2016-01-01", []int{1, 2}},
+
+		// Missing fields never match, not even !=.
+		{"score < 7", []int{2}},
+		{"score != 10", []int{1, 2}},
+
+		// Lists match when any item does.
+		{"studios = madhouse", []int{1, 2, 3}},
+		{"studios != madhouse", []int{0}},
+		{"genres contains drama", []int{2, 3}},
+		{"title contains 'punch'", []int{1}},
+		{"status in (watching, dropped)", []int{2, 3}},
+		{"status not in (watching, dropped)", []int{0, 1}},
+		{"year in 2010..2020", []int{1, 2}},
+		{"genres in (comedy, drama)", []int{1, 2, 3}},
+
+		{"", []int{0, 1, 2, 3}},
+		{"order by score desc", []int{0, 1, 2, 3}},
+		{"order by score", []int{2, 1, 0, 3}},
+		{"order by year desc, title limit 3", []int{3, 2, 1}},
+		{"studios contains madhouse order by episodes desc, title limit 2", []int{3, 2}},
+		{"STATUS = completed ORDER BY Score ASC", []int{1, 0}},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query, testFields)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if got := q.Select(testRecords); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q).Select() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	q, err := Parse("score > 5 limit 10", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Limit != 10 {
+		t.Errorf("Limit = %d, want 10", q.Limit)
+	}
+	if q.OrderBy != nil {
+		t.Errorf("OrderBy = %v, want none", q.OrderBy)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"score >", "position 8: expected a value, got end of query"},
+		{"rating = 5", `position 1: unknown field "rating"`},
+		{"score 5", `position 7: expected a comparison, 'in' or 'contains' after "score", got "5"`},
+		{"(score > 5", "position 11: expected ')', got end of query"},
+		{"score > 5 and", "position 14: expected a field name, got end of query"},
+		{"score > 5 status = completed", `position 11: unexpected "status"`},
+		{"year in 2010 2020", `position 14: expected '..' in range, got "2020"`},
+		{"year in a..b", `position 9: expected a number, got "a"`},
+		{"status in (a b)", `position 14: expected ',' or ')', got "b"`},
+		{"order score", `position 7: expected 'by' after 'order', got "score"`},
+		{"limit ten", `position 7: expected a number after 'limit', got "ten"`},
+		{"limit -1", `position 7: expected a number after 'limit', got "-1"`},
+		{"title = 'frieren", "unterminated string at position 9"},
+		{"score ! 5", "unexpected '!' at position 7, did you mean '!='?"},
+		{"score > 5 & year = 2015", "unexpected '&' at position 11"},
+		// Positions count runes, not bytes.
+		{"score ≥ ≥", `position 9: expected a value, got ">="`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query, testFields)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want error %q", tt.query, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %q, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	tokens, err := lex(`year in 2015..2019 and title != 'it\'s' and start_date = 2015-04-01 and score >= -1.5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.text)
+	}
+	want := []string{"year", "in", "2015", "..", "2019", "and", "title", "!=", "it's", "and",
+		"start_date", "=", "2015-04-01", "and", "score", ">=", "-1.5", ""}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("lex() = %q, want %q", got, want)
+	}
+
+	kinds := map[string]tokenKind{"2015": tokNumber, "..": tokRange, "it's": tokString, "2015-04-01": tokString, "-1.5": tokNumber}
+	for _, tok := range tokens {
+		if kind, ok := kinds[tok.text]; ok && tok.kind != kind {
+			t.Errorf("token %q has kind %d, want %d", tok.text, tok.kind, kind)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+const (
+	FileName = "queue.json"
+
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Item is a list mutation that could not be sent.
+type Item struct {
+	ID        int                 `json:"id"`
+	Op        string              `json:"op"`
+	AnimeID   int                 `json:"anime_id"`
+	Title     string              `json:"title,omitempty"`
+	Update    api.AnimeListUpdate `json:"update"`
+	QueuedAt  time.Time           `json:"queued_at"`
+	LastError string              `json:"last_error,omitempty"`
+}
+
+func (it Item) String() string {
+	name := it.Title
+	if name == "" {
+		name = fmt.Sprintf("anime %d", it.AnimeID)
+	}
+	if it.Op == OpDelete {
+		return fmt.Sprintf("remove %s", name)
+	}
+	return fmt.Sprintf("update %s: %s", name, it.Update)
+}
+
+// Queue is a durable, ordered list of pending mutations stored as JSON.
+type Queue struct {
+	path   string
+	lock   *os.File
+	NextID int    `json:"next_id"`
+	Items  []Item `json:"items"`
+}
+
+// Open loads the queue at path and holds a lock on it until Close, so that
+// commands changing the queue at the same time don't lose each other's items.
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("locking the queue: %w", err)
+	}
+
+	q, err := Load(path)
+	if err != nil {
+		unlockFile(lock)
+		lock.Close()
+		return nil, err
+	}
+	q.lock = lock
+	return q, nil
+}
+
+// Close releases the lock taken by Open.
+func (q *Queue) Close() error {
+	if q.lock == nil {
+		return nil
+	}
+	err := unlockFile(q.lock)
+	if closeErr := q.lock.Close(); err == nil {
+		err = closeErr
+	}
+	q.lock = nil
+	return err
+}
+
+// Load reads the queue without locking it, for reading only.
+func Load(path string) (*Queue, error) {
+	q := &Queue{path: path, NextID: 1}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) Add(item Item) (Item, error) {
+	item.ID = q.NextID
+	q.NextID++
+	if item.QueuedAt.IsZero() {
+		item.QueuedAt = time.Now()
+	}
+
+	q.Items = append(q.Items, item)
+	return item, q.Save()
+}
+
+func (q *Queue) Remove(id int) bool {
+	for i, it := range q.Items {
+		if it.ID == id {
+			q.Items = append(q.Items[:i], q.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Queue) Save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(q.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(q.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), q.path)
+}
+
+// Conflict is reported when the entry changed on MAL after the mutation was
+// queued, so replaying it could overwrite newer data.
+type Conflict struct {
+	Item            Item
+	RemoteUpdatedAt time.Time
+}
+
+type FlushResult struct {
+	Sent      []Item
+	Conflicts []Conflict
+	Failed    []Item
+	// Unavailable is set when flushing stopped because MAL could not be
+	// reached, the remaining items stay queued.
+	Unavailable error
+}
+
+// Flush replays the queued items in order. Items that conflict with newer
+// remote changes are kept, as are all later items for the same anime, unless
+// force is set. Once an item for an anime is sent, the later ones aren't
+// checked again, as the remote change is that item.
+func (q *Queue) Flush(accessToken string, force bool) (FlushResult, error) {
+	var result FlushResult
+	blocked := map[int]bool{}
+	sent := map[int]bool{}
+
+	var remaining []Item
+	for i, it := range q.Items {
+		if blocked[it.AnimeID] {
+			remaining = append(remaining, it)
+			continue
+		}
+
+		if !force && !sent[it.AnimeID] {
+			remote, err := remoteUpdatedAt(it.AnimeID, accessToken)
+			if err != nil && api.IsUnavailable(err) {
+				result.Unavailable = err
+				remaining = append(remaining, q.Items[i:]...)
+				break
+			}
+			if err == nil && remote.After(it.QueuedAt) {
+				result.Conflicts = append(result.Conflicts, Conflict{Item: it, RemoteUpdatedAt: remote})
+				blocked[it.AnimeID] = true
+				remaining = append(remaining, it)
+				continue
+			}
+		}
+
+		err := Send(it, accessToken)
+		if err != nil && api.IsUnavailable(err) {
+			result.Unavailable = err
+			remaining = append(remaining, q.Items[i:]...)
+			break
+		}
+		if err != nil {
+			it.LastError = err.Error()
+			result.Failed = append(result.Failed, it)
+			blocked[it.AnimeID] = true
+			remaining = append(remaining, it)
+			continue
+		}
+
+		result.Sent = append(result.Sent, it)
+		sent[it.AnimeID] = true
+	}
+
+	q.Items = remaining
+	return result, q.Save()
+}
+
+// Send applies a single mutation right away.
+func Send(it Item, accessToken string) error {
+	switch it.Op {
+	case OpUpdate:
+		_, err := api.UpdateAnimeListStatus(it.AnimeID, it.Update, accessToken)
+		return err
+	case OpDelete:
+		err := api.DeleteAnimeListItem(it.AnimeID, accessToken)
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 404 {
+			// Already gone, nothing left to do.
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown queued operation %q", it.Op)
+	}
+}
+
+// remoteUpdatedAt returns when the entry was last changed on MAL, the zero
+// time if it is not on the list.
+func remoteUpdatedAt(animeID int, accessToken string) (time.Time, error) {
+	// Even a list status cached for minutes is too old to compare with.
+	api.InvalidateCache(fmt.Sprintf("anime/%d", animeID))
+
+	details, err := api.GetAnimeDetails(animeID, "my_list_status", accessToken)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if details.MyListStatus == nil {
+		return time.Time{}, nil
+	}
+	return details.MyListStatus.UpdatedAt, nil
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestOpenConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	// Each goroutine opens the queue on its own, like separate commands.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(animeID int) {
+			defer wg.Done()
+			q, err := Open(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer q.Close()
+			if _, err := q.Add(Item{Op: OpDelete, AnimeID: animeID}); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	q, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Items) != 20 || q.NextID != 21 {
+		t.Fatalf("queue has %d items and next ID %d, want 20 and 21", len(q.Items), q.NextID)
+	}
+	ids := map[int]bool{}
+	for _, it := range q.Items {
+		ids[it.ID] = true
+	}
+	if len(ids) != 20 {
+		t.Errorf("queued IDs aren't unique: %v", q.Items)
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) > 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	q, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rinem/ani-track/api"
+)
+
+const (
+	maxReasons  = 3
+	maxSimilar  = 2
+	minSimilar  = 0.3
+	likedMargin = 1.0
+)
+
+// Candidate is an anime that could be recommended, with where it was found
+// (e.g. "top ranked", "fall 2026", "related to Monster").
+type Candidate struct {
+	Anime   api.Anime
+	Sources []string
+}
+
+type Pick struct {
+	Anime   api.Anime `json:"anime"`
+	Score   float64   `json:"score"`
+	Reasons []string  `json:"reasons"`
+	Sources []string  `json:"sources"`
+}
+
+type vector map[string]float64
+
+// Features describes an anime by its genres and themes, studios, source
+// material and media type, normalised to unit length.
+func Features(a api.Anime) vector {
+	v := vector{}
+	for _, g := range a.Genres {
+		v["genre:"+g.Name] = 1
+	}
+	for _, s := range a.Studios {
+		v["studio:"+s.Name] = 1
+	}
+	if a.Source != "" {
+		v["source:"+a.Source] = 1
+	}
+	if a.MediaType != "" {
+		v["type:"+a.MediaType] = 0.5
+	}
+	return v.normalized()
+}
+
+func (v vector) normalized() vector {
+	norm := v.norm()
+	if norm == 0 {
+		return v
+	}
+	for k := range v {
+		v[k] /= norm
+	}
+	return v
+}
+
+func (v vector) norm() float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+func (v vector) dot(o vector) float64 {
+	sum := 0.0
+	for k, x := range v {
+		sum += x * o[k]
+	}
+	return sum
+}
+
+type likedTitle struct {
+	entry    api.AnimeListEntry
+	features vector
+}
+
+// Model is a taste profile built from a scored list. Titles scored above
+// the user's mean pull the profile towards their features, titles below
+// push it away.
+type Model struct {
+	profile vector
+	liked   []likedTitle
+	onList  map[int]bool
+}
+
+func NewModel(list []api.AnimeListEntry) *Model {
+	m := &Model{profile: vector{}, onList: map[int]bool{}}
+
+	sum, n := 0, 0
+	for _, e := range list {
+		m.onList[e.Node.ID] = true
+		if e.ListStatus.Score > 0 {
+			sum += e.ListStatus.Score
+			n++
+		}
+	}
+	if n == 0 {
+		return m
+	}
+	mean := float64(sum) / float64(n)
+
+	for _, e := range list {
+		if e.ListStatus.Score == 0 {
+			continue
+		}
+		f := Features(e.Node)
+		weight := float64(e.ListStatus.Score) - mean
+		for k, x := range f {
+			m.profile[k] += weight * x
+		}
+		if weight >= likedMargin {
+			m.liked = append(m.liked, likedTitle{entry: e, features: f})
+		}
+	}
+
+	return m
+}
+
+// Liked returns the titles scored clearly above the user's mean, best
+// first. They seed the related-anime candidates.
+func (m *Model) Liked() []api.AnimeListEntry {
+	liked := make([]api.AnimeListEntry, len(m.liked))
+	for i, l := range m.liked {
+		liked[i] = l.entry
+	}
+	sort.SliceStable(liked, func(i, j int) bool { return liked[i].ListStatus.Score > liked[j].ListStatus.Score })
+	return liked
+}
+
+// OnList reports whether the anime is on the list, and so is never
+// recommended.
+func (m *Model) OnList(id int) bool {
+	return m.onList[id]
+}
+
+// Recommend ranks the candidates that are not on the list by cosine
+// similarity to the taste profile and returns the best n.
+func (m *Model) Recommend(candidates []Candidate, n int) []Pick {
+	profileNorm := m.profile.norm()
+	if profileNorm == 0 {
+		return nil
+	}
+
+	merged := mergeCandidates(candidates)
+	var picks []Pick
+	for _, c := range merged {
+		if m.OnList(c.Anime.ID) {
+			continue
+		}
+
+		f := Features(c.Anime)
+		score := m.profile.dot(f) / profileNorm
+		if score <= 0 {
+			continue
+		}
+
+		picks = append(picks, Pick{
+			Anime:   c.Anime,
+			Score:   score,
+			Reasons: m.explain(f),
+			Sources: c.Sources,
+		})
+	}
+
+	sort.SliceStable(picks, func(i, j int) bool {
+		if picks[i].Score != picks[j].Score {
+			return picks[i].Score > picks[j].Score
+		}
+		return picks[i].Anime.Mean > picks[j].Anime.Mean
+	})
+	if n > 0 && len(picks) > n {
+		picks = picks[:n]
+	}
+	return picks
+}
+
+// explain lists the features that contributed most and the liked titles
+// the candidate is most similar to.
+func (m *Model) explain(f vector) []string {
+	type contribution struct {
+		feature string
+		value   float64
+	}
+	var contributions []contribution
+	for k, x := range f {
+		if c := m.profile[k] * x; c > 0 {
+			contributions = append(contributions, contribution{k, c})
+		}
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		if contributions[i].value != contributions[j].value {
+			return contributions[i].value > contributions[j].value
+		}
+		return contributions[i].feature < contributions[j].feature
+	})
+
+	var reasons []string
+	var features []string
+	for i, c := range contributions {
+		if i == maxReasons {
+			break
+		}
+		features = append(features, describeFeature(c.feature))
+	}
+	if len(features) > 0 {
+		reasons = append(reasons, "you rate "+strings.Join(features, ", ")+" highly")
+	}
+
+	type similar struct {
+		title string
+		score int
+		sim   float64
+	}
+	var sims []similar
+	for _, l := range m.liked {
+		if sim := l.features.dot(f); sim >= minSimilar {
+			sims = append(sims, similar{l.entry.Node.Title, l.entry.ListStatus.Score, sim})
+		}
+	}
+	sort.Slice(sims, func(i, j int) bool { return sims[i].sim > sims[j].sim })
+	for i, s := range sims {
+		if i == maxSimilar {
+			break
+		}
+		reasons = append(reasons, fmt.Sprintf("similar to %s (you gave it %d)", s.title, s.score))
+	}
+
+	return reasons
+}
+
+func describeFeature(feature string) string {
+	kind, value, _ := strings.Cut(feature, ":")
+	switch kind {
+	case "studio":
+		return "studio " + value
+	case "source":
+		return strings.ReplaceAll(value, "_", " ") + " adaptations"
+	case "type":
+		return value + "s"
+	}
+	return value
+}
+
+// mergeCandidates joins duplicates found through several sources, keeping
+// the copy with the most details.
+func mergeCandidates(candidates []Candidate) []Candidate {
+	index := map[int]int{}
+	var merged []Candidate
+	for _, c := range candidates {
+		i, ok := index[c.Anime.ID]
+		if !ok {
+			index[c.Anime.ID] = len(merged)
+			merged = append(merged, Candidate{Anime: c.Anime, Sources: append([]string(nil), c.Sources...)})
+			continue
+		}
+		if len(c.Anime.Genres) > len(merged[i].Anime.Genres) {
+			merged[i].Anime = c.Anime
+		}
+		merged[i].Sources = append(merged[i].Sources, c.Sources...)
+	}
+	return merged
+}
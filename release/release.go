@@ -0,0 +1,288 @@
+package release
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Release is what could be read from the file name of an anime release.
+// Fields that weren't found are left empty.
+type Release struct {
+	Title  string `json:"title"`
+	Season int    `json:"season,omitempty"`
+	// Episode is the first episode of the file, EpisodeEnd the last one of
+	// a batch like "01-12".
+	Episode    int `json:"episode,omitempty"`
+	EpisodeEnd int `json:"episode_end,omitempty"`
+	// Special is the kind of extra, e.g. OVA, SP, NCOP, with Episode as
+	// its number.
+	Special    string `json:"special,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Year       int    `json:"year,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Checksum   string `json:"checksum,omitempty"`
+	Extension  string `json:"extension,omitempty"`
+}
+
+// HasEpisode reports whether the release is a single numbered episode of
+// the series, rather than a batch, an extra or something unnumbered.
+func (r Release) HasEpisode() bool {
+	return r.Episode > 0 && r.EpisodeEnd == 0 && r.Special == ""
+}
+
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".webm": true,
+	".mov": true, ".wmv": true, ".flv": true, ".ts": true, ".m2ts": true,
+	".ogm": true, ".rmvb": true,
+}
+
+var subtitleExtensions = map[string]bool{".ass": true, ".srt": true, ".ssa": true}
+
+// IsVideo reports whether filename has a video extension.
+func IsVideo(filename string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+var (
+	bracketPattern    = regexp.MustCompile(`\[([^\]]*)\]|\(([^)]*)\)|【([^】]*)】`)
+	checksumPattern   = regexp.MustCompile(`^[0-9A-Fa-f]{8}$`)
+	yearPattern       = regexp.MustCompile(`^(?:19|20)\d\d$`)
+	resolutionPattern = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(\d{3,4}[pi]|\d{3,4}x\d{3,4}|4k|uhd)(?:[^0-9a-z]|$)`)
+	// sceneGroupPattern needs a letter other than E, so the end of a range
+	// like S01E01-E03 isn't taken for the group.
+	sceneGroupPattern = regexp.MustCompile(`-([A-Za-z0-9]*[A-DF-Za-df-z][A-Za-z0-9]*)$`)
+	// tagPattern matches source, codec and audio tags, which only follow
+	// the title and episode.
+	tagPattern    = regexp.MustCompile(`(?i)(?:^|[\s.\[(])(?:[hx][ .]?26[45]|hevc|avc|xvid|aac(?:[ .]?\d[ .]\d)?|flac|opus|e?ac3|ddp?(?:[ .]?\d[ .]\d)?|\d{1,2}[ .-]?bits?|web(?:-?dl|-?rip)?|bd(?:rip)?|blu-?ray|dvd(?:rip)?|hdtv|dual[ .-]audio|multi[ .-]?subs?)(?:[\s.\])]|$)`)
+	spacesPattern = regexp.MustCompile(`\s+`)
+)
+
+// markers find the season, episode and special in what is left of the
+// name once brackets and resolutions are removed. The title is everything
+// before the first one. Earlier markers win when several set the same
+// field.
+var markers = []struct {
+	pattern *regexp.Regexp
+	apply   func(r *Release, m []string)
+}{
+	{ // S02E05, S02E05v2, S01E01-E12
+		regexp.MustCompile(`(?i)\bS(\d{1,2})\s?E(\d{1,4})(?:v(\d))?(?:\s?[-~]\s?E?(\d{1,4}))?\b`),
+		func(r *Release, m []string) {
+			r.Season = atoi(m[1])
+			r.Episode, r.Version, r.EpisodeEnd = atoi(m[2]), atoi(m[3]), atoi(m[4])
+		},
+	},
+	{ // 2x05
+		regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`),
+		func(r *Release, m []string) { r.Season, r.Episode = atoi(m[1]), atoi(m[2]) },
+	},
+	{ // Season 2, 2nd Season, S2
+		regexp.MustCompile(`(?i)\b(?:season\s?(\d{1,2})|(\d{1,2})(?:st|nd|rd|th)\s+season|S(\d{1,2}))\b`),
+		func(r *Release, m []string) { r.Season = atoi(m[1] + m[2] + m[3]) },
+	},
+	{ // OVA 2, NCOP1, SP. The short ones are case sensitive as they are
+		// also common words.
+		regexp.MustCompile(`\b(?:((?i:OVA|OAD|ONA|Specials?))|(NCOP|NCED|OP|ED|SP|PV|CM))\s?(\d{1,3})?(?:v(\d))?\b`),
+		func(r *Release, m []string) {
+			r.Special = strings.ToUpper(m[1] + m[2])
+			if strings.HasPrefix(r.Special, "SPECIAL") {
+				r.Special = "SP"
+			}
+			r.Episode, r.Version = atoi(m[3]), atoi(m[4])
+		},
+	},
+	{ // Title - 05, Title - 05v2, Title - 01-12
+		regexp.MustCompile(`(?:^|\s)[-~]\s(\d{1,4})(?:v(\d))?(?:\s?[-~]\s?(\d{1,4})(?:v\d)?)?(?:\s|$)`),
+		func(r *Release, m []string) { r.Episode, r.Version, r.EpisodeEnd = atoi(m[1]), atoi(m[2]), atoi(m[3]) },
+	},
+	{ // Title - 12.5, a recap between two episodes without a number of
+		// its own.
+		regexp.MustCompile(`(?:^|\s)[-~]\s\d{1,4}\.\d(?:v\d)?(?:\s|$)`),
+		func(r *Release, m []string) { r.Special = "SP" },
+	},
+	{ // Episode 5, Ep05, E05, #05
+		regexp.MustCompile(`(?i)(?:\b(?:episode|ep\.?|e)\s?|#)(\d{1,4})(?:v(\d))?\b`),
+		func(r *Release, m []string) { r.Episode, r.Version = atoi(m[1]), atoi(m[2]) },
+	},
+}
+
+// bareEpisode is the fallback for names like "Title 05" or "Title 05v2":
+// the last standalone number.
+var bareEpisode = regexp.MustCompile(`(?:^|\s)(\d{1,4})(?:v(\d))?(?:\s|$)`)
+
+// Parse reads the title, season, episode and release details from the
+// name of an anime file, following the usual fansub and scene naming such
+// as "[Group] Title - 05v2 (1080p) [ABCD1234].mkv" and
+// "Title.S02E05.1080p.WEB.x264-GROUP.mkv". Directories in filename are
+// ignored.
+func Parse(filename string) Release {
+	var r Release
+
+	name := filepath.Base(filename)
+	if ext := filepath.Ext(name); IsVideo(ext) || subtitleExtensions[strings.ToLower(ext)] {
+		r.Extension = strings.ToLower(ext[1:])
+		name = strings.TrimSuffix(name, ext)
+	}
+	name = strings.TrimSpace(name)
+
+	// Scene names separate words with dots and end with the group.
+	scene := !strings.Contains(bracketPattern.ReplaceAllString(name, ""), " ")
+	name = r.parseBrackets(name)
+	if scene {
+		if m := sceneGroupPattern.FindStringSubmatch(name); m != nil && r.Group == "" && strings.Contains(name, ".") {
+			r.Group = m[1]
+			name = strings.TrimSuffix(name, m[0])
+		}
+		name = strings.ReplaceAll(name, ".", " ")
+	}
+	name = strings.ReplaceAll(name, "_", " ")
+
+	if loc := resolutionPattern.FindStringSubmatchIndex(name); loc != nil {
+		if r.Resolution == "" {
+			r.Resolution = strings.ToLower(name[loc[2]:loc[3]])
+		}
+		name = name[:loc[2]]
+	}
+	if loc := tagPattern.FindStringIndex(name); loc != nil && loc[0] > 0 {
+		name = name[:loc[0]]
+	}
+
+	r.parseMarkers(name)
+	return r
+}
+
+// parseBrackets takes the group, checksum, year and resolution from the
+// bracketed parts of name and returns it without them.
+func (r *Release) parseBrackets(name string) string {
+	var rest strings.Builder
+	last := 0
+	for _, loc := range bracketPattern.FindAllStringSubmatchIndex(name, -1) {
+		rest.WriteString(name[last:loc[0]])
+		rest.WriteString(" ")
+		last = loc[1]
+
+		var inner string
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] >= 0 {
+				inner = strings.TrimSpace(name[loc[i]:loc[i+1]])
+			}
+		}
+
+		switch {
+		case checksumPattern.MatchString(inner):
+			r.Checksum = strings.ToUpper(inner)
+		case yearPattern.MatchString(inner):
+			r.Year = atoi(inner)
+		case loc[0] == 0 && name[0] != '(' && !resolutionPattern.MatchString(inner):
+			r.Group = inner
+		default:
+			if m := resolutionPattern.FindStringSubmatch(inner); m != nil && r.Resolution == "" {
+				r.Resolution = strings.ToLower(m[1])
+			}
+		}
+	}
+	rest.WriteString(name[last:])
+	return rest.String()
+}
+
+// parseMarkers sets the season, episode and special from name and takes
+// the title from before the first of them.
+func (r *Release) parseMarkers(name string) {
+	type found struct {
+		start, end int
+		apply      func(r *Release, m []string)
+		match      []string
+	}
+	var all []found
+	for _, marker := range markers {
+		loc := marker.pattern.FindStringSubmatchIndex(name)
+		if loc == nil {
+			continue
+		}
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = name[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		all = append(all, found{loc[0], loc[1], marker.apply, match})
+	}
+
+	// Drop markers overlapping an earlier, more specific one, like the S2
+	// in S2E05 or the E05 in it.
+	var kept []found
+	for _, f := range all {
+		overlaps := false
+		for _, k := range kept {
+			overlaps = overlaps || f.start < k.end && k.start < f.end
+		}
+		if !overlaps {
+			kept = append(kept, f)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].start < kept[j].start })
+
+	titleEnd := len(name)
+	episodeStart := -1
+	for _, f := range kept {
+		var fields Release
+		f.apply(&fields, f.match)
+		if fields.Special != "" && episodeStart >= 0 {
+			// An OP or ED after the episode is part of its title.
+			continue
+		}
+		if titleEnd == len(name) {
+			titleEnd = f.start
+		}
+		if fields.Episode > 0 && episodeStart < 0 {
+			episodeStart = f.start
+		}
+		r.merge(fields)
+	}
+
+	if titleEnd == len(name) && r.Episode == 0 {
+		// Fall back to the last number, as long as something is left for
+		// the title or the name is nothing but the number.
+		locs := bareEpisode.FindAllStringSubmatchIndex(name, -1)
+		if len(locs) > 0 {
+			loc := locs[len(locs)-1]
+			number := name[loc[2]:loc[3]]
+			alone := strings.TrimSpace(name) == number
+			if (alone || strings.TrimSpace(name[:loc[0]]) != "") && !yearPattern.MatchString(number) {
+				r.Episode = atoi(number)
+				if loc[4] >= 0 {
+					r.Version = atoi(name[loc[4]:loc[5]])
+				}
+				titleEnd = loc[0]
+			}
+		}
+	}
+
+	title := spacesPattern.ReplaceAllString(name[:titleEnd], " ")
+	r.Title = strings.Trim(title, " -~:")
+}
+
+// merge copies the fields set in other that are still empty in r.
+func (r *Release) merge(other Release) {
+	if r.Season == 0 {
+		r.Season = other.Season
+	}
+	if r.Episode == 0 && other.Episode > 0 {
+		r.Episode, r.EpisodeEnd = other.Episode, other.EpisodeEnd
+		if r.Version == 0 {
+			r.Version = other.Version
+		}
+	}
+	if r.Special == "" {
+		r.Special = other.Special
+	}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
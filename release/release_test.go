@@ -0,0 +1,104 @@
+package release
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Release
+	}{
+		{"[SubsPlease] Sousou no Frieren - 05 (1080p) [ABCD1234].mkv",
+			Release{Title: "Sousou no Frieren", Episode: 5, Resolution: "1080p", Group: "SubsPlease", Checksum: "ABCD1234", Extension: "mkv"}},
+		{"[Group] Title - 05v2 (1080p) [CRC].mkv",
+			Release{Title: "Title", Episode: 5, Version: 2, Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+		{"[Group] Title - 05 [720p][1A2B3C4D].mp4",
+			Release{Title: "Title", Episode: 5, Resolution: "720p", Group: "Group", Checksum: "1A2B3C4D", Extension: "mp4"}},
+		{"/home/me/Anime/Frieren/[Group] Title - 05 [720p].mkv",
+			Release{Title: "Title", Episode: 5, Resolution: "720p", Group: "Group", Extension: "mkv"}},
+		{"Title - 05 [1080p].srt",
+			Release{Title: "Title", Episode: 5, Resolution: "1080p", Extension: "srt"}},
+
+		// Seasons.
+		{"Title.S02E05.1080p.WEB.mkv",
+			Release{Title: "Title", Season: 2, Episode: 5, Resolution: "1080p", Extension: "mkv"}},
+		{"[Group] Title - S03E10 (1080p) [HEVC].mkv",
+			Release{Title: "Title", Season: 3, Episode: 10, Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+		{"Cowboy Bebop 1x05 [DVD].avi",
+			Release{Title: "Cowboy Bebop", Season: 1, Episode: 5, Extension: "avi"}},
+		{"[Judas] Mob Psycho 100 S2 - 12.mkv",
+			Release{Title: "Mob Psycho 100", Season: 2, Episode: 12, Group: "Judas", Extension: "mkv"}},
+		{"[Group] Vinland Saga Season 2 - 03 [1080p].mkv",
+			Release{Title: "Vinland Saga", Season: 2, Episode: 3, Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+		{"[Group] Title - 2nd Season - 07 [720p].mkv",
+			Release{Title: "Title", Season: 2, Episode: 7, Resolution: "720p", Group: "Group", Extension: "mkv"}},
+		{"[Group] Title Episode 7 [1080p].mkv",
+			Release{Title: "Title", Episode: 7, Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+
+		// Scene names with the group after a dash.
+		{"Show.Name.S01E01-E03.720p.HDTV.x264-GROUP.mkv",
+			Release{Title: "Show Name", Season: 1, Episode: 1, EpisodeEnd: 3, Resolution: "720p", Group: "GROUP", Extension: "mkv"}},
+
+		// Ranges.
+		{"[Erai-raws] Shingeki no Kyojin - The Final Season - 01 ~ 04 [1080p].mkv",
+			Release{Title: "Shingeki no Kyojin - The Final Season", Episode: 1, EpisodeEnd: 4, Resolution: "1080p", Group: "Erai-raws", Extension: "mkv"}},
+
+		// Specials and recaps.
+		{"[HorribleSubs] Kaguya-sama wa Kokurasetai - OVA [720p].mkv",
+			Release{Title: "Kaguya-sama wa Kokurasetai", Special: "OVA", Resolution: "720p", Group: "HorribleSubs", Extension: "mkv"}},
+		{"[Group] Title - SP2 [1080p].mkv",
+			Release{Title: "Title", Episode: 2, Special: "SP", Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+		{"[Coalgirls] Clannad After Story - NCOP01 (1920x1080 Blu-ray FLAC) [12345678].mkv",
+			Release{Title: "Clannad After Story", Episode: 1, Special: "NCOP", Resolution: "1920x1080", Group: "Coalgirls", Checksum: "12345678", Extension: "mkv"}},
+		{"[SubsPlease] Spy x Family - 12.5 (1080p).mkv",
+			Release{Title: "Spy x Family", Special: "SP", Resolution: "1080p", Group: "SubsPlease", Extension: "mkv"}},
+		{"[Group] Steins;Gate 0 - 23.5 [1080p].mkv",
+			Release{Title: "Steins;Gate 0", Special: "SP", Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+
+		// Movies, with a year instead of an episode.
+		{"[Group] Made in Abyss - Fukaki Tamashii no Reimeiki (2020) [BD 1080p].mkv",
+			Release{Title: "Made in Abyss - Fukaki Tamashii no Reimeiki", Resolution: "1080p", Year: 2020, Group: "Group", Extension: "mkv"}},
+
+		// Numeric titles.
+		{"[SubsPlease] 86 - 05 (1080p).mkv",
+			Release{Title: "86", Episode: 5, Resolution: "1080p", Group: "SubsPlease", Extension: "mkv"}},
+		{"[Group] 22-7 - 05 [1080p].mkv",
+			Release{Title: "22-7", Episode: 5, Resolution: "1080p", Group: "Group", Extension: "mkv"}},
+		{"04.mkv",
+			Release{Episode: 4, Extension: "mkv"}},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.name); got != tt.want {
+			t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHasEpisode(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"[Group] Title - 05.mkv", true},
+		{"[Group] Title - 01-12 [Batch].mkv", false},
+		{"[Group] Title - OVA2.mkv", false},
+		{"[Group] Title (2020).mkv", false},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.name).HasEpisode(); got != tt.want {
+			t.Errorf("Parse(%q).HasEpisode() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsVideo(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.mkv": true, "a.MP4": true, "dir/a.m2ts": true,
+		"a.srt": false, "a.ass": false, "a.txt": false, "mkv": false,
+	} {
+		if got := IsVideo(name); got != want {
+			t.Errorf("IsVideo(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SourceJellyfin = "jellyfin"
+	SourcePlex     = "plex"
+	SourceKodi     = "kodi"
+)
+
+// ErrNotWatched is returned for webhook events that don't mean an episode
+// was watched, like playback starting or pausing.
+var ErrNotWatched = errors.New("not a watched event")
+
+// Event is an episode or movie a media server reports as watched.
+type Event struct {
+	Source string `json:"source"`
+	User   string `json:"user,omitempty"`
+	// Title is the series, or the movie title for movies.
+	Title   string `json:"title"`
+	Season  int    `json:"season,omitempty"`
+	Episode int    `json:"episode"`
+	// ProviderIDs are metadata IDs of the series by lowercase provider,
+	// e.g. "mal", "anidb" or "tvdb".
+	ProviderIDs map[string]string `json:"provider_ids,omitempty"`
+}
+
+// Key identifies the watched episode, to recognise repeated webhooks. The
+// provider IDs tell apart events of different series without a title.
+func (e Event) Key() string {
+	ids := make([]string, 0, len(e.ProviderIDs))
+	for provider, id := range e.ProviderIDs {
+		ids = append(ids, provider+":"+id)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("%s|%s|%s|%s|%d|%d", e.Source, strings.ToLower(e.User), strings.ToLower(e.Title), strings.Join(ids, ","), e.Season, e.Episode)
+}
+
+// providerAliases maps provider names used by the servers and their
+// plugins to the ones used in Event.ProviderIDs.
+var providerAliases = map[string]string{
+	"myanimelist": "mal",
+	"thetvdb":     "tvdb",
+	"themoviedb":  "tmdb",
+}
+
+func providerName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := providerAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// ParseJellyfin reads a notification of the Jellyfin webhook plugin. The
+// plugin's payload is a user defined template, so keys are matched case
+// insensitively and values may be strings or numbers. It needs at least
+// NotificationType, ItemType, SeriesName (or Name for movies),
+// SeasonNumber, EpisodeNumber and PlayedToCompletion, and picks up
+// Provider_* keys.
+func ParseJellyfin(r io.Reader) (Event, error) {
+	var raw map[string]any
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return Event{}, fmt.Errorf("invalid Jellyfin payload: %w", err)
+	}
+	fields := make(map[string]any, len(raw))
+	ev := Event{Source: SourceJellyfin, ProviderIDs: map[string]string{}}
+	for k, v := range raw {
+		key := strings.ToLower(k)
+		fields[key] = v
+		if provider, ok := strings.CutPrefix(key, "provider_"); ok && toString(v) != "" {
+			ev.ProviderIDs[providerName(provider)] = toString(v)
+		}
+	}
+
+	switch toString(fields["notificationtype"]) {
+	case "PlaybackStop":
+		if !toBool(fields["playedtocompletion"]) {
+			return Event{}, ErrNotWatched
+		}
+	case "UserDataSaved":
+		if !toBool(fields["played"]) || toString(fields["savereason"]) != "TogglePlayed" {
+			return Event{}, ErrNotWatched
+		}
+	default:
+		return Event{}, ErrNotWatched
+	}
+
+	ev.User = toString(fields["notificationusername"])
+	switch toString(fields["itemtype"]) {
+	case "Episode":
+		ev.Title = toString(fields["seriesname"])
+		ev.Season = toInt(fields["seasonnumber"])
+		ev.Episode = toInt(fields["episodenumber"])
+	case "Movie":
+		ev.Title, ev.Episode = toString(fields["name"]), 1
+	default:
+		return Event{}, ErrNotWatched
+	}
+	return ev, ev.validate()
+}
+
+type plexPayload struct {
+	Event   string `json:"event"`
+	Account struct {
+		Title string `json:"title"`
+	} `json:"Account"`
+	Metadata struct {
+		Type             string `json:"type"`
+		Title            string `json:"title"`
+		GrandparentTitle string `json:"grandparentTitle"`
+		ParentIndex      int    `json:"parentIndex"`
+		Index            int    `json:"index"`
+		GUID             string `json:"guid"`
+		GrandparentGUID  string `json:"grandparentGuid"`
+	} `json:"Metadata"`
+}
+
+// ParsePlex reads the JSON payload field of a Plex webhook. Plex sends
+// media.scrobble once an item is 90% played.
+func ParsePlex(payload []byte) (Event, error) {
+	var p plexPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return Event{}, fmt.Errorf("invalid Plex payload: %w", err)
+	}
+	if p.Event != "media.scrobble" {
+		return Event{}, ErrNotWatched
+	}
+
+	ev := Event{Source: SourcePlex, User: p.Account.Title, ProviderIDs: map[string]string{}}
+	var guid string
+	switch m := p.Metadata; m.Type {
+	case "episode":
+		ev.Title, ev.Season, ev.Episode = m.GrandparentTitle, m.ParentIndex, m.Index
+		guid = m.GrandparentGUID
+	case "movie":
+		ev.Title, ev.Episode = m.Title, 1
+		guid = m.GUID
+	default:
+		return Event{}, ErrNotWatched
+	}
+	if provider, id, ok := parsePlexGUID(guid); ok {
+		ev.ProviderIDs[provider] = id
+	}
+	return ev, ev.validate()
+}
+
+var plexAgentID = regexp.MustCompile(`^(?:([a-z]+)-)?([0-9a-z]+)`)
+
+// parsePlexGUID reads the provider ID from the GUID of a Plex item, like
+// "net.fribbtastic.coding.plex.myanimelist://5114?lang=en" of the
+// MyAnimeList agent, "com.plexapp.agents.hama://anidb-1234" of HAMA or
+// "plex://show/5d9c086c46115600200aa2fe".
+func parsePlexGUID(guid string) (provider, id string, ok bool) {
+	u, err := url.Parse(guid)
+	if err != nil || u.Scheme == "" {
+		return "", "", false
+	}
+	if u.Scheme == "plex" {
+		return "plex", strings.TrimPrefix(u.Host+u.Path, "show/"), true
+	}
+
+	agent := u.Scheme[strings.LastIndex(u.Scheme, ".")+1:]
+	m := plexAgentID.FindStringSubmatch(u.Host)
+	if m == nil {
+		return "", "", false
+	}
+	if m[1] != "" {
+		// HAMA prefixes the ID with its provider.
+		agent = m[1]
+	}
+	return providerName(agent), m[2], true
+}
+
+type kodiItem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	ShowTitle string            `json:"showtitle"`
+	Season    int               `json:"season"`
+	Episode   int               `json:"episode"`
+	PlayCount int               `json:"playcount"`
+	UniqueID  map[string]string `json:"uniqueid"`
+}
+
+type kodiPayload struct {
+	// Event and Item are sent by webhook add-ons, with Item holding the
+	// fields of Kodi's JSON-RPC Player.GetItem.
+	Event string   `json:"event"`
+	User  string   `json:"user"`
+	Item  kodiItem `json:"item"`
+	// Method and Params are a JSON-RPC notification relayed as is.
+	Method string `json:"method"`
+	Params struct {
+		Data struct {
+			End       bool     `json:"end"`
+			PlayCount int      `json:"playcount"`
+			Item      kodiItem `json:"item"`
+		} `json:"data"`
+	} `json:"params"`
+}
+
+// ParseKodi reads a Kodi playback notification. Kodi has no webhooks of its
+// own, so two shapes are accepted: {"event": "watched", "item": {...}}
+// from a webhook add-on, where the event is "watched" or "scrobble" or
+// the item's playcount is set, and a relayed JSON-RPC Player.OnStop with
+// end set or VideoLibrary.OnUpdate with a playcount. Either way the item
+// needs showtitle, season and episode, or a title for movies.
+func ParseKodi(r io.Reader) (Event, error) {
+	var p kodiPayload
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return Event{}, fmt.Errorf("invalid Kodi payload: %w", err)
+	}
+
+	item := p.Item
+	switch {
+	case p.Method == "Player.OnStop" && p.Params.Data.End,
+		p.Method == "VideoLibrary.OnUpdate" && p.Params.Data.PlayCount > 0:
+		item = p.Params.Data.Item
+	case p.Method == "" && (p.Event == "watched" || p.Event == "scrobble" || item.PlayCount > 0):
+	default:
+		return Event{}, ErrNotWatched
+	}
+
+	ev := Event{Source: SourceKodi, User: p.User, ProviderIDs: map[string]string{}}
+	for provider, id := range item.UniqueID {
+		if id != "" {
+			ev.ProviderIDs[providerName(provider)] = id
+		}
+	}
+	switch item.Type {
+	case "episode":
+		ev.Title, ev.Season, ev.Episode = item.ShowTitle, item.Season, item.Episode
+	case "movie":
+		ev.Title, ev.Episode = item.Title, 1
+	default:
+		return Event{}, ErrNotWatched
+	}
+	return ev, ev.validate()
+}
+
+func (e Event) validate() error {
+	if e.Title == "" && len(e.ProviderIDs) == 0 {
+		return fmt.Errorf("%s event has no title or provider IDs", e.Source)
+	}
+	if e.Episode <= 0 {
+		return fmt.Errorf("%s event for %s has no episode number", e.Source, e.Title)
+	}
+	return nil
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func toInt(v any) int {
+	n, _ := strconv.Atoi(toString(v))
+	return n
+}
+
+func toBool(v any) bool {
+	b, _ := strconv.ParseBool(toString(v))
+	return b
+}
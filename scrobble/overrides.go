@@ -0,0 +1,61 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rinem/ani-track/library"
+)
+
+const OverridesFileName = "scrobble.json"
+
+// Overrides map what media servers report to MAL IDs, for shows that are
+// matched wrongly or not at all. The file is only read, it's meant to be
+// edited by hand.
+type Overrides struct {
+	// IDs are keyed by "provider:id", e.g. "anidb:1234", optionally
+	// followed by ":season" for providers like TVDB that keep all seasons
+	// under one ID.
+	IDs map[string]int `json:"ids"`
+	// Titles are keyed by series title, with later seasons written like
+	// "Shingeki no Kyojin S3". Case and punctuation don't matter.
+	Titles map[string]int `json:"titles"`
+
+	titles map[string]int
+}
+
+func LoadOverrides(path string) (*Overrides, error) {
+	o := &Overrides{}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, o); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	o.titles = make(map[string]int, len(o.Titles))
+	for title, id := range o.Titles {
+		o.titles[library.Normalize(title)] = id
+	}
+	return o, nil
+}
+
+// Lookup returns the MAL ID configured for the series of ev.
+func (o *Overrides) Lookup(ev Event) (int, bool) {
+	for provider, id := range ev.ProviderIDs {
+		if malID, ok := o.IDs[fmt.Sprintf("%s:%s:%d", provider, id, ev.Season)]; ok {
+			return malID, true
+		}
+		if malID, ok := o.IDs[provider+":"+id]; ok {
+			return malID, true
+		}
+	}
+	malID, ok := o.titles[library.Key(ev.Title, ev.Season)]
+	return malID, ok
+}
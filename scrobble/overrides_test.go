@@ -0,0 +1,63 @@
+package scrobble
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverridesLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), OverridesFileName)
+	data := `{
+		"ids": {"anidb:1234": 5114, "tvdb:81797:2": 16498, "tvdb:81797": 1},
+		"titles": {"Shingeki no Kyojin S3": 35760, "Steins;Gate": 9253}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o, err := LoadOverrides(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ev   Event
+		want int
+	}{
+		{"provider ID", Event{Title: "Whatever", ProviderIDs: map[string]string{"anidb": "1234"}}, 5114},
+		{"provider ID and season", Event{Season: 2, ProviderIDs: map[string]string{"tvdb": "81797"}}, 16498},
+		{"provider ID of another season", Event{Season: 1, ProviderIDs: map[string]string{"tvdb": "81797"}}, 1},
+		{"title and season", Event{Title: "Shingeki no Kyojin", Season: 3}, 35760},
+		{"title of another season", Event{Title: "Shingeki no Kyojin", Season: 2}, 0},
+		{"title punctuation and case", Event{Title: "steins gate", Season: 1}, 9253},
+		{"title without season", Event{Title: "Steins;Gate"}, 9253},
+		{"unknown provider ID falls back to title", Event{Title: "Steins;Gate", ProviderIDs: map[string]string{"anidb": "1"}}, 9253},
+		{"unknown", Event{Title: "Frieren", ProviderIDs: map[string]string{"mal": "52991"}}, 0},
+	}
+
+	for _, tt := range tests {
+		got, ok := o.Lookup(tt.ev)
+		if got != tt.want || ok != (tt.want != 0) {
+			t.Errorf("%s: Lookup() = %d, %v, want %d", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+
+	o, err := LoadOverrides(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if _, ok := o.Lookup(Event{Title: "A"}); ok {
+		t.Error("empty overrides match")
+	}
+
+	path := filepath.Join(dir, OverridesFileName)
+	os.WriteFile(path, []byte(`{"ids": {"anidb:1": "5114"}}`), 0600)
+	if _, err := LoadOverrides(path); err == nil {
+		t.Error("invalid file loaded without error")
+	}
+}
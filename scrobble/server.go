@@ -0,0 +1,168 @@
+package scrobble
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is how long a repeated event for the same episode is
+// ignored. Servers may report an episode more than once, e.g. Jellyfin on
+// stopping playback and again when it's marked played.
+const DefaultWindow = 12 * time.Hour
+
+// maxBodySize bounds webhook bodies. Plex attaches a thumbnail.
+const maxBodySize = 10 << 20
+
+const (
+	StatusUpdated   = "updated"
+	StatusSkipped   = "skipped"
+	StatusDuplicate = "duplicate"
+	StatusIgnored   = "ignored"
+	StatusUnmatched = "unmatched"
+)
+
+// Result is the response to a webhook.
+type Result struct {
+	Status  string `json:"status"`
+	AnimeID int    `json:"anime_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Episode int    `json:"episode,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Scrobbler marks the episode of an event watched on the list. It reports
+// StatusUpdated, StatusSkipped when the list is already past the episode,
+// or StatusUnmatched when the series isn't known.
+type Scrobbler interface {
+	Scrobble(ev Event) (Result, error)
+}
+
+// Server receives media server webhooks on /jellyfin, /plex and /kodi and
+// passes watched episodes to Scrobbler one at a time.
+type Server struct {
+	Scrobbler Scrobbler
+	// Token, when set, has to be passed as the token query parameter.
+	Token string
+	// Window is how long repeated events are ignored, DefaultWindow when
+	// zero.
+	Window time.Duration
+	// Log receives a line per handled event.
+	Log io.Writer
+	Now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jellyfin", s.handle(func(r *http.Request) (Event, error) {
+		return ParseJellyfin(r.Body)
+	}))
+	mux.HandleFunc("/plex", s.handle(func(r *http.Request) (Event, error) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			return Event{}, fmt.Errorf("invalid Plex webhook: %w", err)
+		}
+		return ParsePlex([]byte(r.FormValue("payload")))
+	}))
+	mux.HandleFunc("/kodi", s.handle(func(r *http.Request) (Event, error) {
+		return ParseKodi(r.Body)
+	}))
+	return mux
+}
+
+func (s *Server) handle(parse func(r *http.Request) (Event, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeResult(w, http.StatusMethodNotAllowed, Result{Status: "error", Message: "use POST"})
+			return
+		}
+		if s.Token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(s.Token)) != 1 {
+			writeResult(w, http.StatusUnauthorized, Result{Status: "error", Message: "missing or wrong token"})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		ev, err := parse(r)
+		if errors.Is(err, ErrNotWatched) {
+			writeResult(w, http.StatusOK, Result{Status: StatusIgnored, Message: err.Error()})
+			return
+		}
+		if err != nil {
+			writeResult(w, http.StatusBadRequest, Result{Status: "error", Message: err.Error()})
+			return
+		}
+
+		result, err := s.scrobble(ev)
+		if err != nil {
+			s.logf("%s: %s episode %d: %v", ev.Source, ev.Title, ev.Episode, err)
+			writeResult(w, http.StatusInternalServerError, Result{Status: "error", Message: err.Error()})
+			return
+		}
+		s.logf("%s: %s episode %d: %s %s", ev.Source, ev.Title, ev.Episode, result.Status, result.Message)
+
+		code := http.StatusOK
+		if result.Status == StatusUnmatched {
+			code = http.StatusUnprocessableEntity
+		}
+		writeResult(w, code, result)
+	}
+}
+
+// scrobble passes ev on unless the same episode was handled within the
+// window. Failed and unmatched events aren't remembered, so the server can
+// retry them.
+func (s *Server) scrobble(ev Event) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	window := s.Window
+	if window == 0 {
+		window = DefaultWindow
+	}
+	if s.seen == nil {
+		s.seen = map[string]time.Time{}
+	}
+	for key, at := range s.seen {
+		if now.Sub(at) >= window {
+			delete(s.seen, key)
+		}
+	}
+
+	key := ev.Key()
+	if _, ok := s.seen[key]; ok {
+		return Result{Status: StatusDuplicate, Episode: ev.Episode, Message: "already handled"}, nil
+	}
+
+	result, err := s.Scrobbler.Scrobble(ev)
+	if err != nil {
+		return Result{}, err
+	}
+	if result.Status == StatusUpdated || result.Status == StatusSkipped {
+		s.seen[key] = now
+	}
+	return result, nil
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "%s "+format+"\n", append([]any{time.Now().Format("15:04:05")}, args...)...)
+	}
+}
+
+func writeResult(w http.ResponseWriter, code int, result Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(result)
+}
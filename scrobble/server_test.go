@@ -0,0 +1,297 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeScrobbler records the events passed on and answers with result, or
+// StatusUpdated by default.
+type fakeScrobbler struct {
+	events []Event
+	result Result
+	err    error
+}
+
+func (f *fakeScrobbler) Scrobble(ev Event) (Result, error) {
+	f.events = append(f.events, ev)
+	if f.err != nil {
+		return Result{}, f.err
+	}
+	result := f.result
+	if result.Status == "" {
+		result = Result{Status: StatusUpdated, AnimeID: 52991, Title: ev.Title, Episode: ev.Episode}
+	}
+	return result, nil
+}
+
+// testServer is a Server with a clock the test moves.
+type testServer struct {
+	*Server
+	scrobbler *fakeScrobbler
+	now       time.Time
+}
+
+func newTestServer() *testServer {
+	ts := &testServer{scrobbler: &fakeScrobbler{}, now: time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)}
+	ts.Server = &Server{Scrobbler: ts.scrobbler, Now: func() time.Time { return ts.now }}
+	return ts
+}
+
+func (ts *testServer) do(t *testing.T, method, target, contentType string, body io.Reader) (int, Result) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	ts.Handler().ServeHTTP(rec, req)
+
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%s %s: invalid response %q: %v", method, target, rec.Body, err)
+	}
+	return rec.Code, result
+}
+
+func (ts *testServer) post(t *testing.T, target, body string) (int, Result) {
+	t.Helper()
+	return ts.do(t, http.MethodPost, target, "application/json", strings.NewReader(body))
+}
+
+// plexBody builds a Plex webhook, a multipart form with the JSON payload
+// and a thumbnail.
+func plexBody(t *testing.T, payload string) (string, io.Reader) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("payload", payload); err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := mw.CreateFormFile("thumb", "thumb.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb.Write(bytes.Repeat([]byte{0xff}, 4096))
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mw.FormDataContentType(), &body
+}
+
+const (
+	jellyfinStopped = `{
+		"NotificationType": "PlaybackStop",
+		"NotificationUsername": "me",
+		"ItemType": "Episode",
+		"SeriesName": "Sousou no Frieren",
+		"SeasonNumber": "01",
+		"EpisodeNumber": 5,
+		"PlayedToCompletion": "True",
+		"Provider_AniDB": "17617",
+		"Provider_tvdb": ""
+	}`
+	plexScrobble = `{
+		"event": "media.scrobble",
+		"Account": {"title": "me"},
+		"Metadata": {
+			"type": "episode",
+			"grandparentTitle": "Sousou no Frieren",
+			"parentIndex": 1,
+			"index": 6,
+			"grandparentGuid": "com.plexapp.agents.hama://anidb-17617?lang=en"
+		}
+	}`
+	kodiWatched = `{
+		"event": "watched",
+		"user": "me",
+		"item": {"type": "episode", "showtitle": "Sousou no Frieren", "season": 1, "episode": 7, "uniqueid": {"tvdb": "424536"}}
+	}`
+	kodiOnUpdate = `{
+		"jsonrpc": "2.0",
+		"method": "VideoLibrary.OnUpdate",
+		"params": {"data": {"playcount": 1, "item": {"type": "movie", "title": "Kimi no Na wa."}}}
+	}`
+)
+
+func TestWebhooks(t *testing.T) {
+	plexType, plexPayload := plexBody(t, plexScrobble)
+
+	tests := []struct {
+		name         string
+		target, body string
+		contentType  string
+		bodyReader   io.Reader
+		want         Event
+	}{
+		{name: "jellyfin", target: "/jellyfin", body: jellyfinStopped,
+			want: Event{Source: SourceJellyfin, User: "me", Title: "Sousou no Frieren", Season: 1, Episode: 5, ProviderIDs: map[string]string{"anidb": "17617"}}},
+		{name: "plex", target: "/plex", contentType: plexType, bodyReader: plexPayload,
+			want: Event{Source: SourcePlex, User: "me", Title: "Sousou no Frieren", Season: 1, Episode: 6, ProviderIDs: map[string]string{"anidb": "17617"}}},
+		{name: "kodi add-on", target: "/kodi", body: kodiWatched,
+			want: Event{Source: SourceKodi, User: "me", Title: "Sousou no Frieren", Season: 1, Episode: 7, ProviderIDs: map[string]string{"tvdb": "424536"}}},
+		{name: "kodi json-rpc", target: "/kodi", body: kodiOnUpdate,
+			want: Event{Source: SourceKodi, Title: "Kimi no Na wa.", Episode: 1, ProviderIDs: map[string]string{}}},
+	}
+
+	for _, tt := range tests {
+		ts := newTestServer()
+		body, contentType := tt.bodyReader, tt.contentType
+		if body == nil {
+			body, contentType = strings.NewReader(tt.body), "application/json"
+		}
+
+		code, result := ts.do(t, http.MethodPost, tt.target, contentType, body)
+		if code != http.StatusOK || result.Status != StatusUpdated {
+			t.Errorf("%s: got %d %+v, want 200 updated", tt.name, code, result)
+		}
+		if len(ts.scrobbler.events) != 1 || !reflect.DeepEqual(ts.scrobbler.events[0], tt.want) {
+			t.Errorf("%s: scrobbled %+v, want %+v", tt.name, ts.scrobbler.events, tt.want)
+		}
+	}
+}
+
+func TestNotWatched(t *testing.T) {
+	plexType, plexPlay := plexBody(t, strings.Replace(plexScrobble, "media.scrobble", "media.play", 1))
+
+	ts := newTestServer()
+	for _, req := range []struct {
+		target, contentType string
+		body                io.Reader
+	}{
+		{"/jellyfin", "application/json", strings.NewReader(strings.Replace(jellyfinStopped, `"True"`, `"False"`, 1))},
+		{"/jellyfin", "application/json", strings.NewReader(strings.Replace(jellyfinStopped, "PlaybackStop", "PlaybackStart", 1))},
+		{"/jellyfin", "application/json", strings.NewReader(strings.Replace(jellyfinStopped, `"Episode"`, `"Audio"`, 1))},
+		{"/plex", plexType, plexPlay},
+		{"/kodi", "application/json", strings.NewReader(`{"method": "Player.OnStop", "params": {"data": {"end": false}}}`)},
+		{"/kodi", "application/json", strings.NewReader(`{"event": "paused", "item": {"type": "episode", "showtitle": "A", "episode": 1}}`)},
+	} {
+		code, result := ts.do(t, http.MethodPost, req.target, req.contentType, req.body)
+		if code != http.StatusOK || result.Status != StatusIgnored {
+			t.Errorf("%s: got %d %+v, want 200 ignored", req.target, code, result)
+		}
+	}
+	if len(ts.scrobbler.events) != 0 {
+		t.Errorf("scrobbled %+v, want nothing", ts.scrobbler.events)
+	}
+}
+
+func TestInvalidPayloads(t *testing.T) {
+	ts := newTestServer()
+	for _, req := range []struct{ target, body string }{
+		{"/jellyfin", "{"},
+		{"/kodi", "not json"},
+		{"/kodi", `{"event": "watched", "item": {"type": "episode", "showtitle": "A"}}`},
+		{"/kodi", `{"event": "watched", "item": {"type": "episode", "episode": 3}}`},
+		// Plex webhooks are multipart forms.
+		{"/plex", plexScrobble},
+	} {
+		if code, result := ts.post(t, req.target, req.body); code != http.StatusBadRequest || result.Status != "error" {
+			t.Errorf("%s %q: got %d %+v, want 400", req.target, req.body, code, result)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	ts := newTestServer()
+	ts.Window = time.Hour
+
+	if _, result := ts.post(t, "/kodi", kodiWatched); result.Status != StatusUpdated {
+		t.Fatalf("first event: %+v", result)
+	}
+
+	// Players report an episode again, e.g. when it is marked played too.
+	ts.now = ts.now.Add(59 * time.Minute)
+	if code, result := ts.post(t, "/kodi", kodiWatched); code != http.StatusOK || result.Status != StatusDuplicate {
+		t.Errorf("repeat within the window: %d %+v, want duplicate", code, result)
+	}
+	// Another user or episode isn't a duplicate.
+	ts.post(t, "/kodi", strings.Replace(kodiWatched, `"me"`, `"you"`, 1))
+	ts.post(t, "/kodi", strings.Replace(kodiWatched, `"episode": 7`, `"episode": 8`, 1))
+	if len(ts.scrobbler.events) != 3 {
+		t.Errorf("scrobbled %d events, want 3", len(ts.scrobbler.events))
+	}
+
+	ts.now = ts.now.Add(time.Minute)
+	if _, result := ts.post(t, "/kodi", kodiWatched); result.Status != StatusUpdated {
+		t.Errorf("repeat after the window: %+v, want updated", result)
+	}
+}
+
+func TestDuplicatesWithoutTitle(t *testing.T) {
+	ts := newTestServer()
+	episode := func(anidb string) string {
+		return `{"event": "watched", "item": {"type": "episode", "episode": 5, "uniqueid": {"anidb": "` + anidb + `"}}}`
+	}
+
+	ts.post(t, "/kodi", episode("1"))
+	if _, result := ts.post(t, "/kodi", episode("2")); result.Status != StatusUpdated {
+		t.Errorf("same episode of another series: %+v, want updated", result)
+	}
+	if _, result := ts.post(t, "/kodi", episode("1")); result.Status != StatusDuplicate {
+		t.Errorf("repeated episode: %+v, want duplicate", result)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	// Failed and unmatched events are passed on again when repeated.
+	ts := newTestServer()
+	ts.scrobbler.result = Result{Status: StatusUnmatched, Message: "no anime found"}
+	for i := 0; i < 2; i++ {
+		if code, result := ts.post(t, "/kodi", kodiWatched); code != http.StatusUnprocessableEntity || result.Status != StatusUnmatched {
+			t.Errorf("unmatched event: %d %+v, want 422", code, result)
+		}
+	}
+
+	ts.scrobbler.result, ts.scrobbler.err = Result{}, errors.New("list unavailable")
+	if code, result := ts.post(t, "/kodi", kodiWatched); code != http.StatusInternalServerError || result.Message != "list unavailable" {
+		t.Errorf("failed event: %d %+v, want 500", code, result)
+	}
+
+	ts.scrobbler.err = nil
+	if _, result := ts.post(t, "/kodi", kodiWatched); result.Status != StatusUpdated {
+		t.Errorf("retried event: %+v, want updated", result)
+	}
+	if len(ts.scrobbler.events) != 4 {
+		t.Errorf("scrobbled %d events, want 4", len(ts.scrobbler.events))
+	}
+}
+
+func TestToken(t *testing.T) {
+	ts := newTestServer()
+	ts.Token = "s3cret"
+
+	for _, target := range []string{"/kodi", "/kodi?token=", "/kodi?token=wrong", "/kodi?token=s3cret2"} {
+		if code, _ := ts.post(t, target, kodiWatched); code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", target, code)
+		}
+	}
+	if len(ts.scrobbler.events) != 0 {
+		t.Errorf("scrobbled %+v without the token", ts.scrobbler.events)
+	}
+
+	if code, result := ts.post(t, "/kodi?token=s3cret", kodiWatched); code != http.StatusOK || result.Status != StatusUpdated {
+		t.Errorf("with token: %d %+v, want updated", code, result)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	ts := newTestServer()
+	for _, target := range []string{"/jellyfin", "/plex", "/kodi"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		ts.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+			t.Errorf("GET %s: got %d, Allow %q, want 405 with Allow: POST", target, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+}
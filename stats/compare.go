@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// minPairs is the number of titles both users scored needed before a
+// correlation means anything.
+const minPairs = 3
+
+type Comparison struct {
+	Shared []SharedTitle `json:"shared"`
+	// BothScored counts shared titles that both users scored.
+	BothScored int      `json:"both_scored"`
+	Pearson    *float64 `json:"pearson,omitempty"`
+	Spearman   *float64 `json:"spearman,omitempty"`
+	// Affinity is the Pearson correlation as a percentage, like MAL's
+	// profile affinity.
+	Affinity *float64 `json:"affinity,omitempty"`
+	// ForSecond are titles the first user completed and the second plans to
+	// watch, ForFirst the other way round.
+	ForSecond []SharedTitle `json:"for_second"`
+	ForFirst  []SharedTitle `json:"for_first"`
+}
+
+type SharedTitle struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	FirstStatus  string `json:"first_status"`
+	FirstScore   int    `json:"first_score"`
+	SecondStatus string `json:"second_status"`
+	SecondScore  int    `json:"second_score"`
+}
+
+// Diff is the second score minus the first, 0 unless both scored.
+func (t SharedTitle) Diff() int {
+	if t.FirstScore == 0 || t.SecondScore == 0 {
+		return 0
+	}
+	return t.SecondScore - t.FirstScore
+}
+
+func Compare(first, second []api.AnimeListEntry) Comparison {
+	var c Comparison
+
+	byID := map[int]api.AnimeListEntry{}
+	for _, e := range second {
+		byID[e.Node.ID] = e
+	}
+
+	var xs, ys []float64
+	for _, a := range first {
+		b, ok := byID[a.Node.ID]
+		if !ok {
+			continue
+		}
+
+		t := SharedTitle{
+			ID:           a.Node.ID,
+			Title:        a.Node.Title,
+			FirstStatus:  a.ListStatus.Status,
+			FirstScore:   a.ListStatus.Score,
+			SecondStatus: b.ListStatus.Status,
+			SecondScore:  b.ListStatus.Score,
+		}
+		c.Shared = append(c.Shared, t)
+
+		if t.FirstScore > 0 && t.SecondScore > 0 {
+			xs = append(xs, float64(t.FirstScore))
+			ys = append(ys, float64(t.SecondScore))
+		}
+
+		switch {
+		case t.FirstStatus == "completed" && t.SecondStatus == "plan_to_watch":
+			c.ForSecond = append(c.ForSecond, t)
+		case t.SecondStatus == "completed" && t.FirstStatus == "plan_to_watch":
+			c.ForFirst = append(c.ForFirst, t)
+		}
+	}
+
+	c.BothScored = len(xs)
+	if len(xs) >= minPairs {
+		if p, ok := Pearson(xs, ys); ok {
+			affinity := p * 100
+			c.Pearson, c.Affinity = &p, &affinity
+		}
+		if s, ok := Pearson(ranks(xs), ranks(ys)); ok {
+			c.Spearman = &s
+		}
+	}
+
+	// Highest combined score first.
+	sort.SliceStable(c.Shared, func(i, j int) bool {
+		return c.Shared[i].FirstScore+c.Shared[i].SecondScore > c.Shared[j].FirstScore+c.Shared[j].SecondScore
+	})
+	sort.SliceStable(c.ForSecond, func(i, j int) bool { return c.ForSecond[i].FirstScore > c.ForSecond[j].FirstScore })
+	sort.SliceStable(c.ForFirst, func(i, j int) bool { return c.ForFirst[i].SecondScore > c.ForFirst[j].SecondScore })
+
+	return c
+}
+
+// Pearson returns the correlation coefficient, ok is false when either
+// series has no variance.
+func Pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if n == 0 || len(xs) != len(ys) {
+		return 0, false
+	}
+
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(vx*vy), true
+}
+
+// ranks returns the fractional ranks of xs, ties get the average rank, as
+// used by Spearman's correlation.
+func ranks(xs []float64) []float64 {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return xs[idx[a]] < xs[idx[b]] })
+
+	r := make([]float64, len(xs))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && xs[idx[j+1]] == xs[idx[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[idx[k]] = avg
+		}
+		i = j + 1
+	}
+	return r
+}
@@ -0,0 +1,210 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// priorWeight is how many average-scored entries are blended into each
+// genre or studio mean, so one 10/10 title does not top the ranking.
+const priorWeight = 3
+
+type Report struct {
+	Entries        int            `json:"entries"`
+	StatusCounts   map[string]int `json:"status_counts"`
+	Scored         int            `json:"scored"`
+	MeanScore      float64        `json:"mean_score"`
+	ScoreHistogram [11]int        `json:"score_histogram"`
+	TotalHours     float64        `json:"total_hours"`
+	// UndatedHours were watched on entries without start or finish dates.
+	UndatedHours   float64   `json:"undated_hours"`
+	HoursByYear    []Period  `json:"hours_by_year"`
+	HoursByMonth   []Period  `json:"hours_by_month"`
+	CompletionRate float64   `json:"completion_rate"`
+	DropRate       float64   `json:"drop_rate"`
+	Genres         []Ranking `json:"genres"`
+	Studios        []Ranking `json:"studios"`
+}
+
+type Period struct {
+	Period string  `json:"period"`
+	Hours  float64 `json:"hours"`
+}
+
+type Ranking struct {
+	Name      string  `json:"name"`
+	Count     int     `json:"count"`
+	Scored    int     `json:"scored"`
+	MeanScore float64 `json:"mean_score"`
+	// Weighted is the mean score shrunk towards the overall mean.
+	Weighted  float64 `json:"weighted"`
+	Completed int     `json:"completed"`
+	Dropped   int     `json:"dropped"`
+	// DropRate is dropped over every entry that is not plan_to_watch.
+	DropRate float64 `json:"drop_rate"`
+
+	started int
+}
+
+// Compute builds the report from list entries that carry genres, studios,
+// num_episodes and average_episode_duration.
+func Compute(entries []api.AnimeListEntry) Report {
+	r := Report{
+		Entries:      len(entries),
+		StatusCounts: map[string]int{},
+	}
+
+	byYear := map[string]float64{}
+	byMonth := map[string]float64{}
+	genres := map[string]*Ranking{}
+	studios := map[string]*Ranking{}
+	scoreSum := 0
+
+	for _, e := range entries {
+		ls := e.ListStatus
+		r.StatusCounts[ls.Status]++
+
+		if ls.Score > 0 {
+			r.Scored++
+			scoreSum += ls.Score
+			r.ScoreHistogram[ls.Score]++
+		}
+
+		hours := WatchedHours(e)
+		r.TotalHours += hours
+		if !spreadHours(e, hours, byYear, byMonth) {
+			r.UndatedHours += hours
+		}
+
+		for _, g := range e.Node.Genres {
+			addRanking(genres, g.Name, ls)
+		}
+		for _, s := range e.Node.Studios {
+			addRanking(studios, s.Name, ls)
+		}
+	}
+
+	if r.Scored > 0 {
+		r.MeanScore = float64(scoreSum) / float64(r.Scored)
+	}
+
+	started := r.Entries - r.StatusCounts["plan_to_watch"]
+	if started > 0 {
+		r.CompletionRate = float64(r.StatusCounts["completed"]) / float64(started)
+		r.DropRate = float64(r.StatusCounts["dropped"]) / float64(started)
+	}
+
+	r.HoursByYear = sortedPeriods(byYear)
+	r.HoursByMonth = sortedPeriods(byMonth)
+	r.Genres = finishRankings(genres, r.MeanScore)
+	r.Studios = finishRankings(studios, r.MeanScore)
+
+	return r
+}
+
+// WatchedHours is watched episodes times the average episode duration.
+func WatchedHours(e api.AnimeListEntry) float64 {
+	return float64(e.ListStatus.NumEpisodesWatched*e.Node.AverageEpisodeDuration) / 3600
+}
+
+// spreadHours distributes hours evenly over the days between the list start
+// and finish dates. Unfinished entries use the last update as the finish,
+// with only one date all hours go to that day.
+func spreadHours(e api.AnimeListEntry, hours float64, byYear, byMonth map[string]float64) bool {
+	if hours == 0 {
+		return true
+	}
+
+	start, startOK := ParseDate(e.ListStatus.StartDate)
+	finish, finishOK := ParseDate(e.ListStatus.FinishDate)
+	if !finishOK && startOK && e.ListStatus.UpdatedAt.After(start) {
+		finish, finishOK = e.ListStatus.UpdatedAt.UTC().Truncate(24*time.Hour), true
+	}
+	switch {
+	case !startOK && !finishOK:
+		return false
+	case !startOK:
+		start = finish
+	case !finishOK:
+		finish = start
+	}
+	if finish.Before(start) {
+		start, finish = finish, start
+	}
+
+	days := int(finish.Sub(start).Hours()/24) + 1
+	perDay := hours / float64(days)
+	for d := 0; d < days; d++ {
+		day := start.AddDate(0, 0, d)
+		byYear[day.Format("2006")] += perDay
+		byMonth[day.Format("2006-01")] += perDay
+	}
+	return true
+}
+
+// ParseDate parses MAL dates, which may be YYYY-MM-DD, YYYY-MM or YYYY.
+func ParseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func addRanking(m map[string]*Ranking, name string, ls api.AnimeListStatus) {
+	rk := m[name]
+	if rk == nil {
+		rk = &Ranking{Name: name}
+		m[name] = rk
+	}
+
+	rk.Count++
+	if ls.Score > 0 {
+		rk.Scored++
+		rk.MeanScore += float64(ls.Score)
+	}
+	switch ls.Status {
+	case "completed":
+		rk.Completed++
+	case "dropped":
+		rk.Dropped++
+	}
+	if ls.Status != "plan_to_watch" {
+		rk.started++
+	}
+}
+
+func finishRankings(m map[string]*Ranking, globalMean float64) []Ranking {
+	rankings := make([]Ranking, 0, len(m))
+	for _, rk := range m {
+		sum := rk.MeanScore
+		if rk.Scored > 0 {
+			rk.MeanScore = sum / float64(rk.Scored)
+		}
+		rk.Weighted = (sum + priorWeight*globalMean) / float64(rk.Scored+priorWeight)
+		if rk.started > 0 {
+			rk.DropRate = float64(rk.Dropped) / float64(rk.started)
+		}
+		rankings = append(rankings, *rk)
+	}
+
+	sort.Slice(rankings, func(i, j int) bool {
+		if rankings[i].Weighted != rankings[j].Weighted {
+			return rankings[i].Weighted > rankings[j].Weighted
+		}
+		return rankings[i].Name < rankings[j].Name
+	})
+	return rankings
+}
+
+func sortedPeriods(m map[string]float64) []Period {
+	periods := make([]Period, 0, len(m))
+	for p, h := range m {
+		periods = append(periods, Period{Period: p, Hours: h})
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Period < periods[j].Period })
+	return periods
+}
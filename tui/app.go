@@ -0,0 +1,544 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// Backend is everything the TUI needs from MyAnimeList. cmd implements it
+// on top of the api package, tests can use a fake.
+type Backend interface {
+	MyList() ([]api.AnimeListEntry, error)
+	Search(query string) ([]api.Anime, error)
+	Season(year int, season string) ([]api.Anime, error)
+	Top(rankingType string) ([]api.Anime, error)
+	Details(id int) (*api.Anime, error)
+	// Update changes the list entry of anime. The returned message tells
+	// the user what happened, e.g. that the change was queued.
+	Update(anime api.Anime, update api.AnimeListUpdate) (string, error)
+}
+
+type Tab int
+
+const (
+	TabMyList Tab = iota
+	TabSearch
+	TabSeason
+	TabTop
+	numTabs
+)
+
+var tabNames = [numTabs]string{"My List", "Search", "Season", "Top"}
+
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeQuery
+	modeScore
+	modeStatus
+)
+
+var seasons = []string{"winter", "spring", "summer", "fall"}
+
+// statusKeys are the keys picking a status after pressing s.
+var statusKeys = map[rune]string{
+	'w': "watching",
+	'c': "completed",
+	'h': "on_hold",
+	'd': "dropped",
+	'p': "plan_to_watch",
+}
+
+// statusOrder sorts My List, most active entries first.
+var statusOrder = map[string]int{
+	"watching":      0,
+	"on_hold":       1,
+	"plan_to_watch": 2,
+	"completed":     3,
+	"dropped":       4,
+}
+
+type list struct {
+	items   []api.Anime
+	loaded  bool
+	loading bool
+	err     error
+	// filter narrows the items by title, query is the Search tab's search.
+	filter string
+	query  string
+	cursor int
+	offset int
+}
+
+// App is the state of the TUI. It is driven by HandleKey and drawn by
+// Render, so it can be exercised without a terminal.
+type App struct {
+	backend Backend
+
+	tab   Tab
+	lists [numTabs]*list
+	// statuses are the user's list statuses by anime ID, shared by all tabs
+	// so an update shows up everywhere.
+	statuses map[int]*api.AnimeListStatus
+
+	seasonYear  int
+	season      string
+	rankingType int
+
+	showDetails bool
+	details     map[int]*api.Anime
+	detailErrs  map[int]error
+
+	mode    mode
+	input   string
+	message string
+
+	// tasks are slow backend calls queued by HandleKey. Running them
+	// separately lets the caller draw a loading state first.
+	tasks []func()
+	quit  bool
+}
+
+func NewApp(backend Backend, now time.Time) *App {
+	a := &App{
+		backend:    backend,
+		statuses:   map[int]*api.AnimeListStatus{},
+		details:    map[int]*api.Anime{},
+		detailErrs: map[int]error{},
+	}
+	for i := range a.lists {
+		a.lists[i] = &list{}
+	}
+	a.seasonYear, a.season = api.CurrentSeason(now)
+	a.load(TabMyList)
+	return a
+}
+
+func (a *App) Quit() bool {
+	return a.quit
+}
+
+// RunTasks performs the backend calls queued since the last call.
+func (a *App) RunTasks() {
+	for a.Pending() {
+		a.runNext()
+	}
+}
+
+func (a *App) runNext() {
+	task := a.tasks[0]
+	a.tasks = a.tasks[1:]
+	task()
+}
+
+func (a *App) Pending() bool {
+	return len(a.tasks) > 0
+}
+
+func (a *App) current() *list {
+	return a.lists[a.tab]
+}
+
+// visible returns the items of l matching its filter.
+func (l *list) visible() []api.Anime {
+	if l.filter == "" {
+		return l.items
+	}
+	filter := strings.ToLower(l.filter)
+	var items []api.Anime
+	for _, item := range l.items {
+		if matchesFilter(item, filter) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func matchesFilter(a api.Anime, filter string) bool {
+	titles := []string{a.Title, a.AlternativeTitles.En, a.AlternativeTitles.Ja}
+	titles = append(titles, a.AlternativeTitles.Synonyms...)
+	for _, t := range titles {
+		if strings.Contains(strings.ToLower(t), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) selected() *api.Anime {
+	items := a.current().visible()
+	if c := a.current().cursor; c >= 0 && c < len(items) {
+		return &items[c]
+	}
+	return nil
+}
+
+func (a *App) status(id int) *api.AnimeListStatus {
+	return a.statuses[id]
+}
+
+// load queues fetching the items of tab.
+func (a *App) load(tab Tab) {
+	l := a.lists[tab]
+	if tab == TabSearch && l.query == "" {
+		l.items, l.loaded, l.err = nil, true, nil
+		return
+	}
+
+	l.loading = true
+	a.tasks = append(a.tasks, func() {
+		var items []api.Anime
+		var err error
+		switch tab {
+		case TabMyList:
+			var entries []api.AnimeListEntry
+			entries, err = a.backend.MyList()
+			items = a.setMyList(entries)
+		case TabSearch:
+			items, err = a.backend.Search(l.query)
+		case TabSeason:
+			items, err = a.backend.Season(a.seasonYear, a.season)
+		case TabTop:
+			items, err = a.backend.Top(api.RankingTypes[a.rankingType])
+		}
+
+		l.loading = false
+		if err != nil {
+			l.err = err
+			return
+		}
+		for _, item := range items {
+			if item.MyListStatus != nil && a.statuses[item.ID] == nil {
+				a.statuses[item.ID] = item.MyListStatus
+			}
+		}
+		l.items, l.loaded, l.err = items, true, nil
+		l.cursor, l.offset = 0, 0
+	})
+}
+
+func (a *App) setMyList(entries []api.AnimeListEntry) []api.Anime {
+	items := make([]api.Anime, len(entries))
+	for i, entry := range entries {
+		status := entry.ListStatus
+		a.statuses[entry.Node.ID] = &status
+		items[i] = entry.Node
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		si, sj := statusOrder[a.statuses[items[i].ID].Status], statusOrder[a.statuses[items[j].ID].Status]
+		if si != sj {
+			return si < sj
+		}
+		return strings.ToLower(items[i].Title) < strings.ToLower(items[j].Title)
+	})
+	return items
+}
+
+func (a *App) switchTab(tab Tab) {
+	a.tab = (tab + numTabs) % numTabs
+	a.message = ""
+	l := a.current()
+	if !l.loaded && !l.loading {
+		a.load(a.tab)
+	}
+	if a.tab == TabSearch && l.query == "" {
+		a.startInput(modeQuery, "")
+	}
+	a.loadDetails()
+}
+
+func (a *App) move(delta int) {
+	l := a.current()
+	n := len(l.visible())
+	l.cursor += delta
+	if l.cursor >= n {
+		l.cursor = n - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+	a.loadDetails()
+}
+
+// loadDetails queues fetching the details of the selection when the detail
+// pane is open.
+func (a *App) loadDetails() {
+	sel := a.selected()
+	if !a.showDetails || sel == nil || a.details[sel.ID] != nil || a.detailErrs[sel.ID] != nil {
+		return
+	}
+	id := sel.ID
+	a.tasks = append(a.tasks, func() {
+		if a.details[id] != nil {
+			return
+		}
+		details, err := a.backend.Details(id)
+		if err != nil {
+			a.detailErrs[id] = err
+			return
+		}
+		a.details[id] = details
+		if details.MyListStatus != nil && a.statuses[id] == nil {
+			a.statuses[id] = details.MyListStatus
+		}
+	})
+}
+
+func (a *App) startInput(m mode, initial string) {
+	a.mode = m
+	a.input = initial
+	a.message = ""
+}
+
+// HandleKey applies one key press.
+func (a *App) HandleKey(k Key) {
+	if k.Code == KeyCtrlC {
+		a.quit = true
+		return
+	}
+	if a.mode != modeNormal {
+		a.handleInput(k)
+		return
+	}
+
+	a.message = ""
+	l := a.current()
+	switch k.Code {
+	case KeyUp:
+		a.move(-1)
+	case KeyDown:
+		a.move(1)
+	case KeyPgUp:
+		a.move(-10)
+	case KeyPgDn:
+		a.move(10)
+	case KeyHome:
+		a.move(-len(l.items))
+	case KeyEnd:
+		a.move(len(l.items))
+	case KeyTab, KeyRight:
+		a.switchTab(a.tab + 1)
+	case KeyBacktab, KeyLeft:
+		a.switchTab(a.tab - 1)
+	case KeyEnter:
+		a.showDetails = !a.showDetails
+		a.loadDetails()
+	case KeyEsc:
+		if l.filter != "" {
+			l.filter = ""
+			l.cursor, l.offset = 0, 0
+		} else {
+			a.showDetails = false
+		}
+	case KeyRune:
+		a.handleRune(k.Rune)
+	}
+}
+
+func (a *App) handleRune(r rune) {
+	l := a.current()
+	switch r {
+	case 'q':
+		a.quit = true
+	case 'k':
+		a.move(-1)
+	case 'j':
+		a.move(1)
+	case 'g':
+		a.move(-len(l.items))
+	case 'G':
+		a.move(len(l.items))
+	case '1', '2', '3', '4':
+		a.switchTab(Tab(r - '1'))
+	case '/':
+		if a.tab == TabSearch {
+			a.startInput(modeQuery, l.query)
+		} else {
+			a.startInput(modeQuery, l.filter)
+		}
+	case 'R':
+		a.load(a.tab)
+	case '+', '=':
+		a.changeEpisodes(1)
+	case '-':
+		a.changeEpisodes(-1)
+	case 's':
+		if a.selected() != nil {
+			a.startInput(modeStatus, "")
+		}
+	case 'r':
+		if sel := a.selected(); sel != nil {
+			score := ""
+			if st := a.status(sel.ID); st != nil && st.Score > 0 {
+				score = strconv.Itoa(st.Score)
+			}
+			a.startInput(modeScore, score)
+		}
+	case '[', ']':
+		if a.tab == TabSeason {
+			a.shiftSeason(map[rune]int{'[': -1, ']': 1}[r])
+		}
+	case 't':
+		if a.tab == TabTop {
+			a.rankingType = (a.rankingType + 1) % len(api.RankingTypes)
+			a.load(TabTop)
+		}
+	}
+}
+
+func (a *App) shiftSeason(delta int) {
+	i := 0
+	for j, s := range seasons {
+		if s == a.season {
+			i = j
+		}
+	}
+	i += delta
+	if i < 0 {
+		i += len(seasons)
+		a.seasonYear--
+	} else if i >= len(seasons) {
+		i -= len(seasons)
+		a.seasonYear++
+	}
+	a.season = seasons[i]
+	a.load(TabSeason)
+}
+
+func (a *App) handleInput(k Key) {
+	switch k.Code {
+	case KeyEsc:
+		a.mode = modeNormal
+		return
+	case KeyBackspace:
+		if r := []rune(a.input); len(r) > 0 {
+			a.input = string(r[:len(r)-1])
+		}
+		return
+	case KeyEnter:
+		a.submitInput()
+		return
+	case KeyRune:
+	default:
+		return
+	}
+
+	switch a.mode {
+	case modeStatus:
+		status, ok := statusKeys[k.Rune]
+		a.mode = modeNormal
+		if !ok {
+			a.message = fmt.Sprintf("Unknown status key %q", k.Rune)
+			return
+		}
+		a.update(api.AnimeListUpdate{Status: &status})
+	case modeScore:
+		if k.Rune >= '0' && k.Rune <= '9' && len(a.input) < 2 {
+			a.input += string(k.Rune)
+		}
+	default:
+		a.input += string(k.Rune)
+	}
+}
+
+func (a *App) submitInput() {
+	m := a.mode
+	a.mode = modeNormal
+	l := a.current()
+
+	switch m {
+	case modeQuery:
+		if a.tab == TabSearch {
+			l.query = strings.TrimSpace(a.input)
+			a.load(TabSearch)
+		} else {
+			l.filter = a.input
+			l.cursor, l.offset = 0, 0
+		}
+	case modeScore:
+		score, err := strconv.Atoi(a.input)
+		if err != nil || score < 0 || score > 10 {
+			a.message = "Scores go from 0 to 10"
+			return
+		}
+		a.update(api.AnimeListUpdate{Score: &score})
+	}
+}
+
+// changeEpisodes moves the watched episodes of the selection by delta,
+// marking the entry as watching or completed like the watched command.
+func (a *App) changeEpisodes(delta int) {
+	sel := a.selected()
+	if sel == nil {
+		return
+	}
+
+	st := a.status(sel.ID)
+	episodes := delta
+	if st != nil {
+		episodes = st.NumEpisodesWatched + delta
+	}
+	if episodes < 0 || (sel.NumEpisodes > 0 && episodes > sel.NumEpisodes) {
+		return
+	}
+
+	update := api.AnimeListUpdate{NumWatchedEpisodes: &episodes}
+	status := ""
+	switch {
+	case sel.NumEpisodes > 0 && episodes == sel.NumEpisodes:
+		status = "completed"
+	case st == nil || st.Status == "plan_to_watch":
+		status = "watching"
+	}
+	if status != "" {
+		update.Status = &status
+	}
+	a.update(update)
+}
+
+// update queues sending update for the selection and applies it locally
+// once the backend accepted it.
+func (a *App) update(update api.AnimeListUpdate) {
+	sel := a.selected()
+	if sel == nil {
+		return
+	}
+	anime := *sel
+	a.message = fmt.Sprintf("Updating %s…", anime.Title)
+	a.tasks = append(a.tasks, func() {
+		message, err := a.backend.Update(anime, update)
+		if err != nil {
+			a.message = "Error: " + err.Error()
+			return
+		}
+		a.applyUpdate(anime, update)
+		a.message = message
+	})
+}
+
+func (a *App) applyUpdate(anime api.Anime, update api.AnimeListUpdate) {
+	st := a.statuses[anime.ID]
+	if st == nil {
+		st = &api.AnimeListStatus{Status: "plan_to_watch"}
+		a.statuses[anime.ID] = st
+		if my := a.lists[TabMyList]; my.loaded {
+			my.items = append(my.items, anime)
+		}
+	}
+	if update.Status != nil {
+		st.Status = *update.Status
+	}
+	if update.Score != nil {
+		st.Score = *update.Score
+	}
+	if update.NumWatchedEpisodes != nil {
+		st.NumEpisodesWatched = *update.NumWatchedEpisodes
+	}
+	st.UpdatedAt = time.Now()
+}
@@ -0,0 +1,319 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+)
+
+// fakeBackend serves fixed lists and records the calls made by the app.
+type fakeBackend struct {
+	list      []api.AnimeListEntry
+	updateErr error
+
+	calls   []string
+	updates []api.AnimeListUpdate
+}
+
+func (f *fakeBackend) MyList() ([]api.AnimeListEntry, error) {
+	f.calls = append(f.calls, "mylist")
+	return f.list, nil
+}
+
+func (f *fakeBackend) Search(query string) ([]api.Anime, error) {
+	f.calls = append(f.calls, "search "+query)
+	return []api.Anime{{ID: 100, Title: "Result for " + query, NumEpisodes: 24}}, nil
+}
+
+func (f *fakeBackend) Season(year int, season string) ([]api.Anime, error) {
+	f.calls = append(f.calls, fmt.Sprintf("season %s %d", season, year))
+	return []api.Anime{{ID: 200, Title: "Seasonal"}}, nil
+}
+
+func (f *fakeBackend) Top(rankingType string) ([]api.Anime, error) {
+	f.calls = append(f.calls, "top "+rankingType)
+	return []api.Anime{{ID: 300, Title: "Ranked"}}, nil
+}
+
+func (f *fakeBackend) Details(id int) (*api.Anime, error) {
+	f.calls = append(f.calls, fmt.Sprintf("details %d", id))
+	return &api.Anime{ID: id, Title: "Details", NumEpisodes: 12, Synopsis: "A synopsis."}, nil
+}
+
+func (f *fakeBackend) Update(anime api.Anime, update api.AnimeListUpdate) (string, error) {
+	if f.updateErr != nil {
+		return "", f.updateErr
+	}
+	f.calls = append(f.calls, fmt.Sprintf("update %d %s", anime.ID, update))
+	f.updates = append(f.updates, update)
+	return "Updated " + anime.Title, nil
+}
+
+func entry(id int, title string, episodes int, status string, watched int) api.AnimeListEntry {
+	var e api.AnimeListEntry
+	e.Node = api.Anime{ID: id, Title: title, NumEpisodes: episodes}
+	e.ListStatus = api.AnimeListStatus{Status: status, NumEpisodesWatched: watched}
+	return e
+}
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// newTestApp returns an app with My List loaded from backend.
+func newTestApp(t *testing.T, backend *fakeBackend) *App {
+	t.Helper()
+	app := NewApp(backend, now)
+	app.RunTasks()
+	backend.calls = nil
+	return app
+}
+
+func press(app *App, keys string) {
+	for _, r := range keys {
+		app.HandleKey(runeKey(r))
+	}
+	app.RunTasks()
+}
+
+func pressKey(app *App, code KeyCode) {
+	app.HandleKey(Key{Code: code})
+	app.RunTasks()
+}
+
+func checkCalls(t *testing.T, backend *fakeBackend, want ...string) {
+	t.Helper()
+	if strings.Join(backend.calls, "; ") != strings.Join(want, "; ") {
+		t.Errorf("backend calls = %q, want %q", backend.calls, want)
+	}
+	backend.calls = nil
+}
+
+func TestTabs(t *testing.T) {
+	backend := &fakeBackend{list: []api.AnimeListEntry{entry(1, "Frieren", 28, "watching", 3)}}
+	app := NewApp(backend, now)
+	if !app.Pending() {
+		t.Fatal("My List isn't loaded on start")
+	}
+	app.RunTasks()
+	checkCalls(t, backend, "mylist")
+
+	// The Search tab starts with the search prompt.
+	pressKey(app, KeyTab)
+	if app.tab != TabSearch || app.mode != modeQuery {
+		t.Fatalf("tab %d, mode %d after tab, want the search prompt", app.tab, app.mode)
+	}
+	checkCalls(t, backend)
+	press(app, "mob psycho")
+	pressKey(app, KeyEnter)
+	checkCalls(t, backend, "search mob psycho")
+	if sel := app.selected(); sel == nil || sel.ID != 100 {
+		t.Errorf("selected %v after searching, want the first result", sel)
+	}
+
+	press(app, "3")
+	checkCalls(t, backend, "season fall 2026")
+	press(app, "]")
+	checkCalls(t, backend, "season winter 2027")
+	press(app, "[")
+	press(app, "[")
+	checkCalls(t, backend, "season fall 2026", "season summer 2026")
+
+	press(app, "4")
+	checkCalls(t, backend, "top all")
+	press(app, "t")
+	checkCalls(t, backend, "top airing")
+
+	// Loaded tabs aren't fetched again when switching back.
+	pressKey(app, KeyTab)
+	if app.tab != TabMyList {
+		t.Errorf("tab %d after tab on the last tab, want My List", app.tab)
+	}
+	pressKey(app, KeyBacktab)
+	press(app, "1")
+	checkCalls(t, backend)
+
+	press(app, "R")
+	checkCalls(t, backend, "mylist")
+
+	pressKey(app, KeyEnter)
+	checkCalls(t, backend, "details 1")
+	press(app, "q")
+	if !app.Quit() {
+		t.Error("q doesn't quit")
+	}
+}
+
+func TestEpisodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry api.AnimeListEntry
+		key   string
+		want  string
+	}{
+		{"next episode", entry(1, "A", 12, "watching", 3), "+", "episodes=4"},
+		{"previous episode", entry(1, "A", 12, "watching", 3), "-", "episodes=2"},
+		{"none below 0", entry(1, "A", 12, "watching", 0), "-", ""},
+		{"none past the last", entry(1, "A", 12, "completed", 12), "+", ""},
+		{"last completes", entry(1, "A", 12, "watching", 11), "+", "status=completed episodes=12"},
+		{"planned starts watching", entry(1, "A", 12, "plan_to_watch", 0), "+", "status=watching episodes=1"},
+		{"unknown count", entry(1, "A", 0, "watching", 40), "+", "episodes=41"},
+	}
+
+	for _, tt := range tests {
+		backend := &fakeBackend{list: []api.AnimeListEntry{tt.entry}}
+		app := newTestApp(t, backend)
+		press(app, tt.key)
+
+		got := ""
+		if len(backend.updates) > 0 {
+			got = backend.updates[0].String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: update %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.want != "" && !strings.Contains(app.Render(100, 10), "Updated A") {
+			t.Errorf("%s: backend message isn't shown", tt.name)
+		}
+	}
+}
+
+func TestEpisodesNotOnList(t *testing.T) {
+	backend := &fakeBackend{}
+	app := newTestApp(t, backend)
+	press(app, "4")
+	press(app, "+")
+
+	if len(backend.updates) != 1 || backend.updates[0].String() != "status=watching episodes=1" {
+		t.Fatalf("updates %v, want watching episode 1", backend.updates)
+	}
+	if st := app.status(300); st == nil || st.NumEpisodesWatched != 1 || st.Status != "watching" {
+		t.Errorf("status after the update = %+v", st)
+	}
+	// My List is loaded, so the new entry is added to it.
+	press(app, "1")
+	if items := app.current().items; len(items) != 1 || items[0].ID != 300 {
+		t.Errorf("My List = %v, want the updated anime", items)
+	}
+}
+
+func TestStatusAndScore(t *testing.T) {
+	backend := &fakeBackend{list: []api.AnimeListEntry{entry(1, "A", 12, "watching", 3)}}
+	app := newTestApp(t, backend)
+
+	press(app, "sc")
+	if st := app.status(1); st.Status != "completed" {
+		t.Errorf("status = %q after s c, want completed", st.Status)
+	}
+
+	press(app, "sx")
+	if !strings.Contains(app.Render(100, 10), `Unknown status key 'x'`) {
+		t.Error("unknown status key isn't reported")
+	}
+
+	press(app, "r8")
+	pressKey(app, KeyEnter)
+	if st := app.status(1); st.Score != 8 {
+		t.Errorf("score = %d after r 8, want 8", st.Score)
+	}
+
+	// The prompt starts with the current score, a third digit is ignored.
+	press(app, "r")
+	if app.input != "8" {
+		t.Errorf("score prompt starts with %q, want 8", app.input)
+	}
+	pressKey(app, KeyBackspace)
+	press(app, "123")
+	pressKey(app, KeyEnter)
+	if !strings.Contains(app.Render(100, 10), "Scores go from 0 to 10") {
+		t.Error("score 12 isn't rejected")
+	}
+
+	press(app, "r")
+	pressKey(app, KeyEsc)
+	checkCalls(t, backend, "update 1 status=completed", "update 1 score=8")
+	if app.mode != modeNormal {
+		t.Error("esc doesn't leave the score prompt")
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	backend := &fakeBackend{
+		list:      []api.AnimeListEntry{entry(1, "A", 12, "watching", 3)},
+		updateErr: errors.New("boom"),
+	}
+	app := newTestApp(t, backend)
+	press(app, "+")
+
+	if st := app.status(1); st.NumEpisodesWatched != 3 {
+		t.Errorf("episodes = %d after a failed update, want 3", st.NumEpisodesWatched)
+	}
+	if !strings.Contains(app.Render(100, 10), "Error: boom") {
+		t.Error("update error isn't shown")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	backend := &fakeBackend{list: []api.AnimeListEntry{
+		entry(1, "Frieren", 28, "watching", 3),
+		entry(2, "Mob Psycho 100", 12, "completed", 12),
+	}}
+	app := newTestApp(t, backend)
+
+	press(app, "/mob")
+	pressKey(app, KeyEnter)
+	if sel := app.selected(); sel == nil || sel.ID != 2 || len(app.current().visible()) != 1 {
+		t.Fatalf("selected %v after filtering, want Mob Psycho 100 alone", sel)
+	}
+	pressKey(app, KeyEsc)
+	if len(app.current().visible()) != 2 {
+		t.Error("esc doesn't clear the filter")
+	}
+	checkCalls(t, backend)
+}
+
+var ansi = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func TestRender(t *testing.T) {
+	backend := &fakeBackend{list: []api.AnimeListEntry{
+		entry(1, "Frieren", 28, "watching", 3),
+		entry(2, "Mob Psycho 100", 12, "completed", 12),
+	}}
+	app := newTestApp(t, backend)
+
+	const width, height = 100, 8
+	screen := ansi.ReplaceAllString(app.Render(width, height), "")
+	lines := strings.Split(screen, "\r\n")
+	if len(lines) != height {
+		t.Fatalf("Render drew %d lines, want %d", len(lines), height)
+	}
+	for i, line := range lines {
+		if w := displayWidth(line); w != width {
+			t.Errorf("line %d is %d wide, want %d: %q", i, w, width, line)
+		}
+	}
+	for _, want := range []string{"1 My List", "4 Top", "2 entries", "TITLE", "Frieren", "watching", "3/28", "completed", "12/12", "q quit"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen doesn't show %q:\n%s", want, screen)
+		}
+	}
+	// Watching sorts before completed.
+	if strings.Index(screen, "Frieren") > strings.Index(screen, "Mob Psycho") {
+		t.Error("My List isn't sorted by status")
+	}
+
+	pressKey(app, KeyEnter)
+	screen = ansi.ReplaceAllString(app.Render(width, 12), "")
+	for _, want := range []string{"Details", "12 eps", "On your list: watching", "A synopsis."} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("detail pane doesn't show %q:\n%s", want, screen)
+		}
+	}
+
+	if got := app.Render(20, 3); got != fit("Terminal too small", 20) {
+		t.Errorf("Render(20, 3) = %q", got)
+	}
+}
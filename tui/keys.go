@@ -0,0 +1,93 @@
+package tui
+
+import "unicode/utf8"
+
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPgUp
+	KeyPgDn
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyEsc
+	KeyTab
+	KeyBacktab
+	KeyBackspace
+	KeyCtrlC
+	KeyUnknown
+)
+
+// Key is one key press. Rune is only set for KeyRune.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+func runeKey(r rune) Key {
+	return Key{Code: KeyRune, Rune: r}
+}
+
+// csiKeys maps the final part of the escape sequences sent by xterm
+// compatible terminals, including the Windows console in VT input mode.
+var csiKeys = map[string]KeyCode{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[H": KeyHome, "[F": KeyEnd, "OH": KeyHome, "OF": KeyEnd,
+	"[1~": KeyHome, "[4~": KeyEnd, "[7~": KeyHome, "[8~": KeyEnd,
+	"[5~": KeyPgUp, "[6~": KeyPgDn, "[Z": KeyBacktab,
+}
+
+// ParseKeys splits input read from a raw mode terminal into key presses.
+// An escape byte that is not followed by a known sequence in the same read
+// is the Esc key.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			key, n := parseEscape(b)
+			keys = append(keys, key)
+			b = b[n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c < 0x20:
+			keys = append(keys, Key{Code: KeyUnknown})
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, runeKey(r))
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+func parseEscape(b []byte) (Key, int) {
+	if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+		return Key{Code: KeyEsc}, 1
+	}
+	// A CSI sequence ends with a byte in 0x40-0x7e after the introducer.
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			if code, ok := csiKeys[string(b[1:i+1])]; ok {
+				return Key{Code: code}, i + 1
+			}
+			return Key{Code: KeyUnknown}, i + 1
+		}
+	}
+	return Key{Code: KeyUnknown}, len(b)
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rinem/ani-track/fuzzy"
+)
+
+var ErrCancelled = errors.New("cancelled")
+
+// picker is the state of Pick, kept apart from the terminal like App.
+type picker struct {
+	prompt  string
+	options []string
+	pattern string
+	matches []int
+	cursor  int
+	offset  int
+	done    bool
+	chosen  int
+}
+
+func newPicker(prompt string, options []string) *picker {
+	p := &picker{prompt: prompt, options: options, chosen: -1}
+	p.filter()
+	return p
+}
+
+func (p *picker) filter() {
+	p.matches = fuzzy.Filter(p.pattern, p.options)
+	p.cursor, p.offset = 0, 0
+}
+
+func (p *picker) HandleKey(k Key) {
+	switch k.Code {
+	case KeyEsc, KeyCtrlC:
+		p.done = true
+	case KeyEnter:
+		if len(p.matches) > 0 {
+			p.chosen = p.matches[p.cursor]
+			p.done = true
+		}
+	case KeyUp, KeyBacktab:
+		p.move(-1)
+	case KeyDown, KeyTab:
+		p.move(1)
+	case KeyPgUp:
+		p.move(-10)
+	case KeyPgDn:
+		p.move(10)
+	case KeyBackspace:
+		if r := []rune(p.pattern); len(r) > 0 {
+			p.pattern = string(r[:len(r)-1])
+			p.filter()
+		}
+	case KeyRune:
+		p.pattern += string(k.Rune)
+		p.filter()
+	}
+}
+
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p *picker) Render(width, height int) string {
+	lines := []string{
+		fit(p.prompt+" "+p.pattern+"█", width),
+		dim + fit(fmt.Sprintf("  %d/%d  ↑↓ move  enter choose  esc cancel", len(p.matches), len(p.options)), width) + reset,
+	}
+
+	rows := height - len(lines)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+	for i := p.offset; i < len(p.matches) && i < p.offset+rows; i++ {
+		if i == p.cursor {
+			lines = append(lines, reverse+fit("> "+p.options[p.matches[i]], width)+reset)
+		} else {
+			lines = append(lines, fit("  "+p.options[p.matches[i]], width))
+		}
+	}
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// Pick lets the user fuzzy filter options and choose one, returning its
+// index or ErrCancelled. It needs an interactive terminal, see IsTerminal.
+func Pick(prompt string, options []string) (int, error) {
+	term, err := OpenTerminal()
+	if err != nil {
+		return -1, fmt.Errorf("switching the terminal to raw mode: %w", err)
+	}
+	defer term.Restore()
+
+	fmt.Fprint(term.Out, enterAltScreen)
+	defer fmt.Fprint(term.Out, exitAltScreen)
+
+	resized := make(chan struct{}, 1)
+	stop := watchResize(resized)
+	defer stop()
+
+	p := newPicker(prompt, options)
+	keys := term.Keys()
+	for !p.done {
+		width, height, err := term.Size()
+		if err != nil {
+			return -1, err
+		}
+		fmt.Fprint(term.Out, "\x1b[H"+p.Render(width, height)+"\x1b[J")
+
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return -1, ErrCancelled
+			}
+			p.HandleKey(k)
+		case <-resized:
+		}
+	}
+
+	if p.chosen < 0 {
+		return -1, ErrCancelled
+	}
+	return p.chosen, nil
+}
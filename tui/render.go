@@ -0,0 +1,417 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rinem/ani-track/api"
+)
+
+const (
+	minWidth  = 44
+	minHeight = 6
+	// minDetailWidth is the narrowest detail pane worth showing next to
+	// the list, below it the pane replaces the list.
+	minDetailWidth = 30
+	// rowColumnsWidth is the width of the columns after the title,
+	// including the spaces between them.
+	rowColumnsWidth = 44
+)
+
+var statusLabels = map[string]string{
+	"watching":      "watching",
+	"completed":     "completed",
+	"on_hold":       "on hold",
+	"dropped":       "dropped",
+	"plan_to_watch": "planned",
+}
+
+// Render draws the whole screen as lines separated by CRLF, each padded to
+// width so it overwrites the previous frame.
+func (a *App) Render(width, height int) string {
+	if width < minWidth || height < minHeight {
+		return fit("Terminal too small", width)
+	}
+
+	lines := []string{a.renderTabs(width), dim + fit(a.renderContext(), width) + reset}
+	bodyHeight := height - 3
+
+	listWidth, detailWidth := width, 0
+	if a.showDetails {
+		detailWidth = width * 2 / 5
+		if detailWidth < minDetailWidth {
+			detailWidth = width
+		}
+		listWidth = width - detailWidth
+		if listWidth > 0 {
+			listWidth--
+		}
+	}
+
+	var listLines, detailLines []string
+	if listWidth > 0 {
+		listLines = a.renderList(listWidth, bodyHeight)
+	}
+	if detailWidth > 0 {
+		detailLines = a.renderDetails(detailWidth, bodyHeight)
+	}
+	for i := 0; i < bodyHeight; i++ {
+		switch {
+		case listWidth == 0:
+			lines = append(lines, detailLines[i])
+		case detailWidth == 0:
+			lines = append(lines, listLines[i])
+		default:
+			lines = append(lines, listLines[i]+dim+"│"+reset+detailLines[i])
+		}
+	}
+
+	lines = append(lines, a.renderFooter(width))
+	return strings.Join(lines, "\r\n")
+}
+
+func (a *App) renderTabs(width int) string {
+	var sb strings.Builder
+	used := 0
+	for i, name := range tabNames {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		used += displayWidth(label) + 1
+		if Tab(i) == a.tab {
+			sb.WriteString(reverse + bold + label + reset + " ")
+		} else {
+			sb.WriteString(label + " ")
+		}
+	}
+	const title = "ani-track "
+	if pad := width - used; pad >= len(title) {
+		sb.WriteString(strings.Repeat(" ", pad-len(title)) + dim + title + reset)
+	} else if pad > 0 {
+		sb.WriteString(strings.Repeat(" ", pad))
+	}
+	return sb.String()
+}
+
+func (a *App) renderContext() string {
+	l := a.current()
+	var context string
+	switch a.tab {
+	case TabMyList:
+		context = fmt.Sprintf("%d entries", len(l.items))
+	case TabSearch:
+		if l.query == "" {
+			context = "Press / to search"
+		} else {
+			context = fmt.Sprintf("Results for %q", l.query)
+		}
+	case TabSeason:
+		context = fmt.Sprintf("%s %d   [ ] change season", capitalize(a.season), a.seasonYear)
+	case TabTop:
+		context = fmt.Sprintf("Ranking: %s   t change ranking", api.RankingTypes[a.rankingType])
+	}
+	if l.filter != "" {
+		context += fmt.Sprintf("   filter: %q (%d shown, esc clears)", l.filter, len(l.visible()))
+	}
+	return " " + context
+}
+
+func (a *App) renderList(width, height int) []string {
+	l := a.current()
+	lines := make([]string, 0, height)
+	items := l.visible()
+
+	switch {
+	case l.loading:
+		lines = append(lines, fit(" Loading…", width))
+	case l.err != nil:
+		lines = append(lines, fit(" Error: "+l.err.Error(), width))
+	case l.loaded && len(items) == 0:
+		lines = append(lines, fit(" Nothing to show", width))
+	default:
+		lines = append(lines, bold+a.renderRow(width, "TITLE", "TYPE", "YEAR", "MEAN", "STATUS", "PROGRESS", "SCORE")+reset)
+
+		rows := height - 1
+		if l.cursor < l.offset {
+			l.offset = l.cursor
+		}
+		if l.cursor >= l.offset+rows {
+			l.offset = l.cursor - rows + 1
+		}
+		for i := l.offset; i < len(items) && i < l.offset+rows; i++ {
+			row := a.renderItem(items[i], width)
+			if i == l.cursor {
+				row = reverse + row + reset
+			}
+			lines = append(lines, row)
+		}
+	}
+
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+func (a *App) renderItem(item api.Anime, width int) string {
+	status, progress, score := "", "", ""
+	if st := a.status(item.ID); st != nil {
+		status = statusLabels[st.Status]
+		progress = strconv.Itoa(st.NumEpisodesWatched) + "/"
+		if item.NumEpisodes > 0 {
+			progress += strconv.Itoa(item.NumEpisodes)
+		} else {
+			progress += "?"
+		}
+		if st.Score > 0 {
+			score = strconv.Itoa(st.Score)
+		}
+	}
+
+	year, mean := "", ""
+	if item.StartSeason != nil {
+		year = strconv.Itoa(item.StartSeason.Year)
+	} else if len(item.StartDate) >= 4 {
+		year = item.StartDate[:4]
+	}
+	if item.Mean > 0 {
+		mean = strconv.FormatFloat(item.Mean, 'f', 2, 64)
+	}
+
+	return a.renderRow(width, item.Title, strings.ToUpper(item.MediaType), year, mean, status, progress, score)
+}
+
+// renderRow lays out the list columns, dropping all but title and status
+// when the list is narrow.
+func (a *App) renderRow(width int, title, mediaType, year, mean, status, progress, score string) string {
+	if width < rowColumnsWidth+20 {
+		titleWidth := width - 11
+		return " " + fit(title, titleWidth) + " " + fit(status, 9)
+	}
+	titleWidth := width - rowColumnsWidth - 1
+	return " " + fit(title, titleWidth) + " " +
+		fit(mediaType, 7) + " " + fit(year, 4) + " " + fit(mean, 5) + " " +
+		fit(status, 9) + " " + fit(progress, 8) + " " + fit(score, 5)
+}
+
+func (a *App) renderDetails(width, height int) []string {
+	var lines []string
+	add := func(s string) {
+		for _, line := range wrap(s, width-2) {
+			lines = append(lines, " "+fit(line, width-1))
+		}
+	}
+
+	sel := a.selected()
+	switch {
+	case sel == nil:
+		add("Nothing selected")
+	case a.detailErrs[sel.ID] != nil:
+		add("Error: " + a.detailErrs[sel.ID].Error())
+	case a.details[sel.ID] == nil:
+		add(sel.Title)
+		add("Loading details…")
+	default:
+		d := a.details[sel.ID]
+		lines = append(lines, " "+bold+fit(d.Title, width-1)+reset)
+		if d.AlternativeTitles.En != "" && d.AlternativeTitles.En != d.Title {
+			add(d.AlternativeTitles.En)
+		}
+		if d.AlternativeTitles.Ja != "" {
+			add(d.AlternativeTitles.Ja)
+		}
+		add("")
+
+		var facts []string
+		if d.MediaType != "" {
+			facts = append(facts, strings.ToUpper(d.MediaType))
+		}
+		if d.NumEpisodes > 0 {
+			facts = append(facts, fmt.Sprintf("%d eps", d.NumEpisodes))
+		}
+		if d.StartSeason != nil {
+			facts = append(facts, fmt.Sprintf("%s %d", capitalize(d.StartSeason.Season), d.StartSeason.Year))
+		}
+		if d.Status != "" {
+			facts = append(facts, strings.ReplaceAll(d.Status, "_", " "))
+		}
+		if len(facts) > 0 {
+			add(strings.Join(facts, " · "))
+		}
+
+		if d.Mean > 0 {
+			add(fmt.Sprintf("Score %.2f · Rank #%d · Popularity #%d", d.Mean, d.Rank, d.Popularity))
+		}
+		if len(d.Genres) > 0 {
+			var genres []string
+			for _, g := range d.Genres {
+				genres = append(genres, g.Name)
+			}
+			add("Genres: " + strings.Join(genres, ", "))
+		}
+		if len(d.Studios) > 0 {
+			var studios []string
+			for _, s := range d.Studios {
+				studios = append(studios, s.Name)
+			}
+			add("Studios: " + strings.Join(studios, ", "))
+		}
+		if d.Broadcast != nil && d.Broadcast.DayOfTheWeek != "" {
+			add(fmt.Sprintf("Airs %ss at %s JST", capitalize(d.Broadcast.DayOfTheWeek), d.Broadcast.StartTime))
+		}
+		if st := a.status(d.ID); st != nil {
+			line := "On your list: " + statusLabels[st.Status]
+			if st.Score > 0 {
+				line += fmt.Sprintf(", scored %d", st.Score)
+			}
+			add(line)
+		} else {
+			add("Not on your list")
+		}
+		if d.Synopsis != "" {
+			add("")
+			for _, paragraph := range strings.Split(strings.TrimSpace(d.Synopsis), "\n") {
+				if strings.TrimSpace(paragraph) != "" {
+					add(strings.TrimSpace(paragraph))
+				}
+			}
+		}
+	}
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+func (a *App) renderFooter(width int) string {
+	switch a.mode {
+	case modeQuery:
+		prompt := "Filter: "
+		if a.tab == TabSearch {
+			prompt = "Search: "
+		}
+		return fit(prompt+a.input+"█", width)
+	case modeScore:
+		return fit("Score (0-10, enter to save): "+a.input+"█", width)
+	case modeStatus:
+		return fit("Status: [w]atching [c]ompleted on [h]old [d]ropped [p]lan to watch, esc cancels", width)
+	}
+	if a.message != "" {
+		return fit(a.message, width)
+	}
+	return dim + fit("↑↓ move  tab switch  enter details  / search  +/- episode  s status  r score  R reload  q quit", width) + reset
+}
+
+// displayWidth approximates the number of cells s takes, counting East Asian
+// wide characters as two.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// fit truncates or pads s to exactly width cells.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	w := displayWidth(s)
+	if w <= width {
+		return s + strings.Repeat(" ", width-w)
+	}
+
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if used+rw > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		used += rw
+	}
+	sb.WriteString("…")
+	used++
+	return sb.String() + strings.Repeat(" ", width-used)
+}
+
+// wrap breaks s into lines of at most width cells at spaces, splitting
+// words that are longer than a line.
+func wrap(s string, width int) []string {
+	if s == "" || width <= 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	line, lineWidth := "", 0
+	for _, word := range strings.Fields(s) {
+		ww := displayWidth(word)
+		for ww > width {
+			if line != "" {
+				lines = append(lines, line)
+				line, lineWidth = "", 0
+			}
+			head, rest := splitWidth(word, width)
+			lines = append(lines, head)
+			word, ww = rest, displayWidth(rest)
+		}
+		switch {
+		case line == "":
+			line, lineWidth = word, ww
+		case lineWidth+1+ww <= width:
+			line += " " + word
+			lineWidth += 1 + ww
+		default:
+			lines = append(lines, line)
+			line, lineWidth = word, ww
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func splitWidth(s string, width int) (string, string) {
+	used := 0
+	for i, r := range s {
+		if used+runeWidth(r) > width {
+			return s[:i], s[i:]
+		}
+		used += runeWidth(r)
+	}
+	return s, ""
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,49 @@
+package tui
+
+import "fmt"
+
+// Run takes over the terminal and drives app until the user quits.
+func Run(app *App) error {
+	term, err := OpenTerminal()
+	if err != nil {
+		return fmt.Errorf("switching the terminal to raw mode: %w", err)
+	}
+	defer term.Restore()
+
+	fmt.Fprint(term.Out, enterAltScreen)
+	defer fmt.Fprint(term.Out, exitAltScreen)
+
+	resized := make(chan struct{}, 1)
+	stop := watchResize(resized)
+	defer stop()
+
+	draw := func() error {
+		width, height, err := term.Size()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(term.Out, "\x1b[H"+app.Render(width, height)+"\x1b[J")
+		return err
+	}
+
+	keys := term.Keys()
+	for !app.Quit() {
+		if err := draw(); err != nil {
+			return err
+		}
+		if app.Pending() {
+			app.runNext()
+			continue
+		}
+
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			app.HandleKey(k)
+		case <-resized:
+		}
+	}
+	return nil
+}
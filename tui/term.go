@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	reverse        = "\x1b[7m"
+	bold           = "\x1b[1m"
+	dim            = "\x1b[2m"
+	reset          = "\x1b[0m"
+)
+
+// IsTerminal reports whether f is an interactive terminal rather than a
+// pipe or a file.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Terminal is stdin and stdout switched to raw mode.
+type Terminal struct {
+	In    io.Reader
+	Out   io.Writer
+	state rawState
+}
+
+// OpenTerminal puts the terminal into raw mode, call Restore to undo it.
+func OpenTerminal() (*Terminal, error) {
+	state, err := makeRaw()
+	if err != nil {
+		return nil, err
+	}
+	return &Terminal{In: os.Stdin, Out: os.Stdout, state: state}, nil
+}
+
+func (t *Terminal) Restore() error {
+	return restore(t.state)
+}
+
+// Size returns the width and height of the terminal in cells.
+func (t *Terminal) Size() (int, int, error) {
+	return size()
+}
+
+var (
+	keysOnce sync.Once
+	keys     chan Key
+)
+
+// Keys returns the key presses read from the terminal. A read cannot be
+// interrupted, so a single reader is shared by everything opening the
+// terminal, otherwise a reader left over from an earlier picker would
+// swallow the next key.
+func (t *Terminal) Keys() <-chan Key {
+	keysOnce.Do(func() {
+		keys = make(chan Key)
+		go func() {
+			defer close(keys)
+			buf := make([]byte, 256)
+			for {
+				n, err := t.In.Read(buf)
+				for _, k := range ParseKeys(buf[:n]) {
+					keys <- k
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+	return keys
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// rawState is the output of `stty -g`, which restores the settings when
+// passed back to stty. Going through stty keeps this free of per-platform
+// termios ioctls.
+type rawState string
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func makeRaw() (rawState, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return "", err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return "", err
+	}
+	return rawState(state), nil
+}
+
+func restore(state rawState) error {
+	_, err := stty(string(state))
+	return err
+}
+
+func size() (int, int, error) {
+	out, err := stty("size")
+	if err != nil {
+		return 0, 0, err
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, fmt.Errorf("unexpected stty size output %q", out)
+	}
+	return cols, rows, nil
+}
+
+// watchResize signals on resized whenever the terminal changes size.
+func watchResize(resized chan<- struct{}) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+type rawState struct {
+	in, out uint32
+}
+
+func makeRaw() (rawState, error) {
+	var state rawState
+	in, out := windows.Handle(os.Stdin.Fd()), windows.Handle(os.Stdout.Fd())
+	if err := windows.GetConsoleMode(in, &state.in); err != nil {
+		return state, err
+	}
+	if err := windows.GetConsoleMode(out, &state.out); err != nil {
+		return state, err
+	}
+
+	rawIn := state.in&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_LINE_INPUT|windows.ENABLE_PROCESSED_INPUT) |
+		windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(in, rawIn); err != nil {
+		return state, err
+	}
+	if err := windows.SetConsoleMode(out, state.out|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		windows.SetConsoleMode(in, state.in)
+		return state, err
+	}
+	return state, nil
+}
+
+func restore(state rawState) error {
+	if err := windows.SetConsoleMode(windows.Handle(os.Stdin.Fd()), state.in); err != nil {
+		return err
+	}
+	return windows.SetConsoleMode(windows.Handle(os.Stdout.Fd()), state.out)
+}
+
+func size() (int, int, error) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0, 0, err
+	}
+	w := int(info.Window.Right-info.Window.Left) + 1
+	h := int(info.Window.Bottom-info.Window.Top) + 1
+	return w, h, nil
+}
+
+// watchResize polls the console size, Windows has no resize signal.
+func watchResize(resized chan<- struct{}) (stop func()) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		w, h, _ := size()
+		for {
+			select {
+			case <-ticker.C:
+				nw, nh, err := size()
+				if err == nil && (nw != w || nh != h) {
+					w, h = nw, nh
+					select {
+					case resized <- struct{}{}:
+					default:
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,148 @@
+package wrapped
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+const chartGenres = 8
+
+func RenderMarkdown(w io.Writer, r Report) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# %s Anime Wrapped %d\n\n", possessive(r.Username), r.Year)
+	if r.Completed == 0 {
+		fmt.Fprintf(&sb, "No anime completed in %d.\n", r.Year)
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	fmt.Fprintf(&sb, "- **%d** titles completed\n", r.Completed)
+	fmt.Fprintf(&sb, "- **%d** episodes, **%.0f** hours\n", r.Episodes, r.Hours)
+	if r.MeanScore > 0 {
+		fmt.Fprintf(&sb, "- Mean score **%.2f**\n", r.MeanScore)
+	}
+	if r.TopStudio != nil {
+		fmt.Fprintf(&sb, "- Most watched studio: **%s** (%d titles, %.0f hours)\n", r.TopStudio.Name, r.TopStudio.Titles, r.TopStudio.Hours)
+	}
+	if r.TopGenre != nil {
+		fmt.Fprintf(&sb, "- Most watched genre: **%s** (%d titles, %.0f hours)\n", r.TopGenre.Name, r.TopGenre.Titles, r.TopGenre.Hours)
+	}
+	if r.FirstFinished != nil {
+		fmt.Fprintf(&sb, "- First completed: **%s** (%s)\n", r.FirstFinished.Title, r.FirstFinished.Finished)
+		fmt.Fprintf(&sb, "- Last completed: **%s** (%s)\n", r.LastFinished.Title, r.LastFinished.Finished)
+	}
+
+	if b := r.LongestBinge; b != nil {
+		fmt.Fprintf(&sb, "\n## Longest binge\n\n**%s**: %d episodes (%.1f hours) in %d day(s), %.1f hours a day.\n",
+			b.Title.Title, b.Episodes, b.Hours, b.Days, b.HoursPerDay)
+	}
+
+	if len(r.TopRated) > 0 {
+		sb.WriteString("\n## Top rated\n\n")
+		for i, t := range r.TopRated {
+			fmt.Fprintf(&sb, "%d. %s (%d/10)\n", i+1, t.Title, t.Score)
+		}
+	}
+
+	sb.WriteString("\n## Hours per month\n\n| Month | Hours |\n| --- | ---: |\n")
+	for m, h := range r.HoursByMonth {
+		fmt.Fprintf(&sb, "| %s | %.1f |\n", time.Month(m + 1).String()[:3], h)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("wrapped").Funcs(template.FuncMap{
+	"hours": func(h float64) string { return fmt.Sprintf("%.0f", h) },
+	"score": func(s float64) string { return fmt.Sprintf("%.2f", s) },
+	"inc":   func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 720px; margin: 2em auto; color: #222; background: #fafafa; }
+h1 { color: #2e51a2; }
+.cards { display: flex; flex-wrap: wrap; gap: 12px; }
+.card { background: #fff; border-radius: 8px; padding: 12px 16px; box-shadow: 0 1px 3px rgba(0,0,0,.15); min-width: 120px; }
+.card b { display: block; font-size: 1.6em; color: #2e51a2; }
+section { margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Report}}{{if eq .Completed 0}}<p>No anime completed in {{.Year}}.</p>{{else}}
+<div class="cards">
+<div class="card"><b>{{.Completed}}</b>titles completed</div>
+<div class="card"><b>{{.Episodes}}</b>episodes</div>
+<div class="card"><b>{{hours .Hours}}</b>hours</div>
+{{if gt .MeanScore 0.0}}<div class="card"><b>{{score .MeanScore}}</b>mean score</div>{{end}}
+</div>
+<section>
+{{with .TopStudio}}<p>Most watched studio: <strong>{{.Name}}</strong> ({{.Titles}} titles, {{hours .Hours}} hours)</p>{{end}}
+{{with .TopGenre}}<p>Most watched genre: <strong>{{.Name}}</strong> ({{.Titles}} titles, {{hours .Hours}} hours)</p>{{end}}
+{{with .FirstFinished}}<p>First completed: <strong>{{.Title}}</strong> ({{.Finished}})</p>{{end}}
+{{with .LastFinished}}<p>Last completed: <strong>{{.Title}}</strong> ({{.Finished}})</p>{{end}}
+</section>
+{{with .LongestBinge}}<section><h2>Longest binge</h2>
+<p><strong>{{.Title.Title}}</strong>: {{.Episodes}} episodes in {{.Days}} day(s), {{printf "%.1f" .HoursPerDay}} hours a day.</p></section>{{end}}
+{{if .TopRated}}<section><h2>Top rated</h2><ol>{{range .TopRated}}<li>{{.Title}} ({{.Score}}/10)</li>{{end}}</ol></section>{{end}}
+{{end}}{{end}}
+{{if .MonthChart}}<section><h2>Hours per month</h2>{{.MonthChart}}</section>{{end}}
+{{if .GenreChart}}<section><h2>Genres</h2>{{.GenreChart}}</section>{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML writes a standalone page, charts are inline SVG.
+func RenderHTML(w io.Writer, r Report) error {
+	data := struct {
+		Title      string
+		Report     Report
+		MonthChart template.HTML
+		GenreChart template.HTML
+	}{
+		Title:  fmt.Sprintf("%s Anime Wrapped %d", possessive(r.Username), r.Year),
+		Report: r,
+	}
+
+	if r.Completed > 0 {
+		labels := make([]string, 12)
+		for m := range labels {
+			labels[m] = time.Month(m + 1).String()[:3]
+		}
+		data.MonthChart = template.HTML(columnChart(labels, r.HoursByMonth[:], 240, "#2e51a2"))
+
+		genres := r.Genres
+		if len(genres) > chartGenres {
+			genres = genres[:chartGenres]
+		}
+		var names []string
+		var hours []float64
+		for _, g := range genres {
+			names = append(names, g.Name)
+			hours = append(hours, g.Hours)
+		}
+		if len(genres) > 0 {
+			data.GenreChart = template.HTML(barChart(names, hours, "#e58b2b"))
+		}
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+func possessive(username string) string {
+	if username == "" || username == "@me" {
+		return "My"
+	}
+	if strings.HasSuffix(username, "s") {
+		return username + "'"
+	}
+	return username + "'s"
+}
@@ -0,0 +1,79 @@
+package wrapped
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+const (
+	chartWidth    = 640
+	chartBarGap   = 6
+	chartLabelPad = 24
+)
+
+// columnChart renders vertical bars with a label under each one.
+func columnChart(labels []string, values []float64, height int, color string) string {
+	max := maxValue(values)
+	plotHeight := float64(height - 2*chartLabelPad)
+	barWidth := float64(chartWidth-chartBarGap*(len(values)+1)) / float64(len(values))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img">`,
+		chartWidth, height, chartWidth, height)
+	for i, v := range values {
+		h := 0.0
+		if max > 0 {
+			h = v / max * plotHeight
+		}
+		x := chartBarGap + float64(i)*(barWidth+chartBarGap)
+		y := float64(chartLabelPad) + plotHeight - h
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="3" fill="%s"><title>%s: %.1f</title></rect>`,
+			x, y, barWidth, h, color, html.EscapeString(labels[i]), v)
+		if v > 0 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle" fill="#555">%.0f</text>`,
+				x+barWidth/2, y-4, v)
+		}
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d" font-size="12" text-anchor="middle" fill="#333">%s</text>`,
+			x+barWidth/2, height-6, html.EscapeString(labels[i]))
+	}
+	sb.WriteString(`</svg>`)
+	return sb.String()
+}
+
+// barChart renders horizontal bars with the label on the left.
+func barChart(labels []string, values []float64, color string) string {
+	const rowHeight, labelWidth = 26, 160
+	height := rowHeight*len(values) + chartBarGap
+	max := maxValue(values)
+	plotWidth := float64(chartWidth - labelWidth - 60)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img">`,
+		chartWidth, height, chartWidth, height)
+	for i, v := range values {
+		w := 0.0
+		if max > 0 {
+			w = v / max * plotWidth
+		}
+		y := chartBarGap + i*rowHeight
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="13" text-anchor="end" fill="#333">%s</text>`,
+			labelWidth-8, y+15, html.EscapeString(labels[i]))
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%d" rx="3" fill="%s"/>`,
+			labelWidth, y, w, rowHeight-chartBarGap, color)
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d" font-size="12" fill="#555">%.1fh</text>`,
+			float64(labelWidth)+w+6, y+15, v)
+	}
+	sb.WriteString(`</svg>`)
+	return sb.String()
+}
+
+func maxValue(values []float64) float64 {
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
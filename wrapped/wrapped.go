@@ -0,0 +1,187 @@
+package wrapped
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rinem/ani-track/api"
+	"github.com/rinem/ani-track/stats"
+)
+
+const topRatedCount = 5
+
+// Report summarises the entries finished in one year.
+type Report struct {
+	Year          int         `json:"year"`
+	Username      string      `json:"username,omitempty"`
+	Completed     int         `json:"completed"`
+	Episodes      int         `json:"episodes"`
+	Hours         float64     `json:"hours"`
+	MeanScore     float64     `json:"mean_score"`
+	LongestBinge  *Binge      `json:"longest_binge,omitempty"`
+	TopRated      []Title     `json:"top_rated"`
+	TopStudio     *Share      `json:"top_studio,omitempty"`
+	TopGenre      *Share      `json:"top_genre,omitempty"`
+	FirstFinished *Title      `json:"first_finished,omitempty"`
+	LastFinished  *Title      `json:"last_finished,omitempty"`
+	HoursByMonth  [12]float64 `json:"hours_by_month"`
+	Genres        []Share     `json:"genres"`
+}
+
+type Title struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Score    int    `json:"score,omitempty"`
+	Finished string `json:"finished,omitempty"`
+	Picture  string `json:"picture,omitempty"`
+}
+
+// Binge is the title watched with the most hours per day between its start
+// and finish dates.
+type Binge struct {
+	Title
+	Episodes    int     `json:"episodes"`
+	Days        int     `json:"days"`
+	Hours       float64 `json:"hours"`
+	HoursPerDay float64 `json:"hours_per_day"`
+}
+
+type Share struct {
+	Name   string  `json:"name"`
+	Titles int     `json:"titles"`
+	Hours  float64 `json:"hours"`
+}
+
+// Build collects the completed entries whose finish date falls in year.
+func Build(entries []api.AnimeListEntry, year int) Report {
+	r := Report{Year: year}
+
+	var finished []api.AnimeListEntry
+	for _, e := range entries {
+		if e.ListStatus.Status != "completed" {
+			continue
+		}
+		date, ok := stats.ParseDate(e.ListStatus.FinishDate)
+		if !ok || date.Year() != year {
+			continue
+		}
+		finished = append(finished, e)
+	}
+
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[i].ListStatus.FinishDate < finished[j].ListStatus.FinishDate
+	})
+
+	studios := map[string]*Share{}
+	genres := map[string]*Share{}
+	scoreSum, scored := 0, 0
+
+	for _, e := range finished {
+		hours := stats.WatchedHours(e)
+		r.Completed++
+		r.Episodes += e.ListStatus.NumEpisodesWatched
+		r.Hours += hours
+		if e.ListStatus.Score > 0 {
+			scoreSum += e.ListStatus.Score
+			scored++
+		}
+
+		if date, ok := stats.ParseDate(e.ListStatus.FinishDate); ok {
+			r.HoursByMonth[date.Month()-1] += hours
+		}
+
+		for _, s := range e.Node.Studios {
+			addShare(studios, s.Name, hours)
+		}
+		for _, g := range e.Node.Genres {
+			addShare(genres, g.Name, hours)
+		}
+
+		if b := binge(e, hours); b != nil && (r.LongestBinge == nil || b.HoursPerDay > r.LongestBinge.HoursPerDay) {
+			r.LongestBinge = b
+		}
+	}
+
+	if scored > 0 {
+		r.MeanScore = float64(scoreSum) / float64(scored)
+	}
+
+	if len(finished) > 0 {
+		first, last := titleOf(finished[0]), titleOf(finished[len(finished)-1])
+		r.FirstFinished, r.LastFinished = &first, &last
+	}
+
+	rated := append([]api.AnimeListEntry(nil), finished...)
+	sort.SliceStable(rated, func(i, j int) bool { return rated[i].ListStatus.Score > rated[j].ListStatus.Score })
+	for _, e := range rated {
+		if e.ListStatus.Score == 0 || len(r.TopRated) == topRatedCount {
+			break
+		}
+		r.TopRated = append(r.TopRated, titleOf(e))
+	}
+
+	r.Genres = sortedShares(genres)
+	if len(r.Genres) > 0 {
+		r.TopGenre = &r.Genres[0]
+	}
+	if s := sortedShares(studios); len(s) > 0 {
+		r.TopStudio = &s[0]
+	}
+
+	return r
+}
+
+func binge(e api.AnimeListEntry, hours float64) *Binge {
+	start, ok1 := stats.ParseDate(e.ListStatus.StartDate)
+	finish, ok2 := stats.ParseDate(e.ListStatus.FinishDate)
+	if !ok1 || !ok2 || hours == 0 || finish.Before(start) {
+		return nil
+	}
+
+	days := int(finish.Sub(start)/(24*time.Hour)) + 1
+	return &Binge{
+		Title:       titleOf(e),
+		Episodes:    e.ListStatus.NumEpisodesWatched,
+		Days:        days,
+		Hours:       hours,
+		HoursPerDay: hours / float64(days),
+	}
+}
+
+func titleOf(e api.AnimeListEntry) Title {
+	return Title{
+		ID:       e.Node.ID,
+		Title:    e.Node.Title,
+		Score:    e.ListStatus.Score,
+		Finished: e.ListStatus.FinishDate,
+		Picture:  e.Node.MainPicture.Medium,
+	}
+}
+
+func addShare(m map[string]*Share, name string, hours float64) {
+	s := m[name]
+	if s == nil {
+		s = &Share{Name: name}
+		m[name] = s
+	}
+	s.Titles++
+	s.Hours += hours
+}
+
+// sortedShares orders by hours watched, then by number of titles.
+func sortedShares(m map[string]*Share) []Share {
+	shares := make([]Share, 0, len(m))
+	for _, s := range m {
+		shares = append(shares, *s)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Hours != shares[j].Hours {
+			return shares[i].Hours > shares[j].Hours
+		}
+		if shares[i].Titles != shares[j].Titles {
+			return shares[i].Titles > shares[j].Titles
+		}
+		return shares[i].Name < shares[j].Name
+	})
+	return shares
+}